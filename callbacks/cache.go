@@ -0,0 +1,73 @@
+package callbacks
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/weibaohui/kom/kom"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// InvalidateCache 变更操作成功后，失效对应资源的查询缓存
+// 只传入Dest对象时，从Dest中获取命名空间及名称
+// 试运行不会修改资源，无需失效
+func InvalidateCache(k *kom.Kubectl) error {
+	stmt := k.Statement
+	if stmt.DryRun {
+		return nil
+	}
+	ns, name := stmt.Target()
+	if !stmt.Namespaced {
+		ns = metav1.NamespaceNone
+	} else if stmt.AllNamespace || len(stmt.NamespaceList) > 1 {
+		ns = metav1.NamespaceAll
+	}
+	if name == "" {
+		k.Tools().InvalidateCache(stmt.GVR, ns)
+		return nil
+	}
+	k.Tools().InvalidateCache(stmt.GVR, ns, name)
+	return nil
+}
+
+// cacheNamespace 查询缓存所属的命名空间范围
+// 集群级资源、全部命名空间以及跨多个命名空间的查询，返回空
+func cacheNamespace(stmt *kom.Statement) string {
+	if !stmt.Namespaced {
+		return metav1.NamespaceNone
+	}
+	if stmt.AllNamespace || len(stmt.NamespaceList) > 1 {
+		return metav1.NamespaceAll
+	}
+	if stmt.Namespace == "" {
+		return metav1.NamespaceDefault
+	}
+	return stmt.Namespace
+}
+
+// getCacheKey 单个资源查询的缓存key，带有集群ID，共享缓存时不同集群互不影响
+func getCacheKey(stmt *kom.Statement) string {
+	gvr := stmt.GVR
+	return fmt.Sprintf("%s/get/%s/%s/%s/%s/%s%s", stmt.ID, gvr.Group, gvr.Version, gvr.Resource, cacheNamespace(stmt), stmt.Name, identitySuffix(stmt))
+}
+
+// listCacheKey 列表查询的缓存key
+// 由命名空间范围以及完整的ListOptions构成，不同的LabelSelector、FieldSelector、命名空间组合不会共用缓存
+func listCacheKey(stmt *kom.Statement, opts metav1.ListOptions) string {
+	gvr := stmt.GVR
+	nsList := make([]string, len(stmt.NamespaceList))
+	copy(nsList, stmt.NamespaceList)
+	sort.Strings(nsList)
+	optsJSON, _ := json.Marshal(opts)
+	return fmt.Sprintf("%s/list/%s/%s/%s/%s/[%s]/%s%s", stmt.ID, gvr.Group, gvr.Version, gvr.Resource, cacheNamespace(stmt), strings.Join(nsList, ","), optsJSON, identitySuffix(stmt))
+}
+
+// identitySuffix 模拟用户身份查询时，缓存key带上身份，不同用户不共用缓存
+func identitySuffix(stmt *kom.Statement) string {
+	if key := stmt.IdentityKey(); key != "" {
+		return "/as/" + key
+	}
+	return ""
+}
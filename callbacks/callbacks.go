@@ -31,15 +31,23 @@ func RegisterDefaultCallbacks(c *kom.ClusterInst) func() {
 
 	createCallback := k.Callback().Create()
 	_ = createCallback.Register("kom:create", Create)
+	_ = createCallback.After("kom:create").Register("kom:cache:invalidate", InvalidateCache)
 
 	updateCallback := k.Callback().Update()
 	_ = updateCallback.Register("kom:update", Update)
+	_ = updateCallback.After("kom:update").Register("kom:cache:invalidate", InvalidateCache)
 
 	patchCallback := k.Callback().Patch()
 	_ = patchCallback.Register("kom:patch", Patch)
+	_ = patchCallback.After("kom:patch").Register("kom:cache:invalidate", InvalidateCache)
 
 	deleteCallback := k.Callback().Delete()
 	_ = deleteCallback.Register("kom:delete", Delete)
+	_ = deleteCallback.After("kom:delete").Register("kom:cache:invalidate", InvalidateCache)
+
+	evictCallback := k.Callback().Evict()
+	_ = evictCallback.Register("kom:pod:evict", Evict)
+	_ = evictCallback.After("kom:pod:evict").Register("kom:cache:invalidate", InvalidateCache)
 
 	execCallback := k.Callback().Exec()
 	_ = execCallback.Register("kom:pod:exec", ExecuteCommand)
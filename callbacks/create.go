@@ -30,9 +30,9 @@ func Create(k *kom.Kubectl) error {
 			ns = metav1.NamespaceDefault
 			unstructuredObj.SetNamespace(ns)
 		}
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Create(ctx, unstructuredObj, metav1.CreateOptions{})
+		res, err = k.DynamicClient().Resource(gvr).Namespace(ns).Create(ctx, unstructuredObj, metav1.CreateOptions{DryRun: dryRun(stmt), FieldManager: stmt.FieldManager})
 	} else {
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Create(ctx, unstructuredObj, metav1.CreateOptions{})
+		res, err = k.DynamicClient().Resource(gvr).Create(ctx, unstructuredObj, metav1.CreateOptions{DryRun: dryRun(stmt), FieldManager: stmt.FieldManager})
 	}
 
 	if err != nil {
@@ -42,5 +42,18 @@ func Create(k *kom.Kubectl) error {
 	if stmt.RemoveManagedFields {
 		utils.RemoveManagedFields(res)
 	}
+	// 将 unstructured 转换回原始对象，获取服务端生成的字段
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(res.Object, stmt.Dest)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// dryRun 试运行时，变更操作的DryRun参数
+func dryRun(stmt *kom.Statement) []string {
+	if stmt.DryRun {
+		return []string{metav1.DryRunAll}
+	}
 	return nil
 }
@@ -18,7 +18,7 @@ func Delete(k *kom.Kubectl) error {
 	forceDelete := stmt.ForceDelete // 增加强制删除标志
 
 	// 修改删除选项以支持强制删除
-	deleteOptions := metav1.DeleteOptions{}
+	deleteOptions := metav1.DeleteOptions{DryRun: dryRun(stmt)}
 	if forceDelete {
 		background := metav1.DeletePropagationBackground
 		deleteOptions.PropagationPolicy = &background
@@ -35,9 +35,9 @@ func Delete(k *kom.Kubectl) error {
 			ns = metav1.NamespaceDefault
 		}
 
-		err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Delete(ctx, name, deleteOptions)
+		err = k.DynamicClient().Resource(gvr).Namespace(ns).Delete(ctx, name, deleteOptions)
 	} else {
-		err = stmt.Kubectl.DynamicClient().Resource(gvr).Delete(ctx, name, deleteOptions)
+		err = k.DynamicClient().Resource(gvr).Delete(ctx, name, deleteOptions)
 	}
 
 	if err != nil {
@@ -0,0 +1,35 @@
+package callbacks
+
+import (
+	"fmt"
+
+	"github.com/weibaohui/kom/kom"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Evict 通过 Eviction API 驱逐Pod，遵守PodDisruptionBudget
+func Evict(k *kom.Kubectl) error {
+	stmt := k.Statement
+	ns := stmt.Namespace
+	name := stmt.Name
+	if name == "" {
+		return fmt.Errorf("驱逐Pod必须指定名称")
+	}
+	if ns == "" {
+		ns = metav1.NamespaceDefault
+	}
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+		},
+		DeleteOptions: &metav1.DeleteOptions{DryRun: dryRun(stmt)},
+	}
+	err := k.Client().PolicyV1().Evictions(ns).Evict(stmt.Context, eviction)
+	if err != nil {
+		return err
+	}
+	stmt.RowsAffected = 1
+	return nil
+}
@@ -28,18 +28,35 @@ func Get(k *kom.Kubectl) error {
 		return err
 	}
 
-	cacheKey := fmt.Sprintf("%s/%s/%s/%s/%s", ns, name, gvr.Group, gvr.Resource, gvr.Version)
-	res, err := utils.GetOrSetCache(stmt.Kubectl.ClusterCache(), cacheKey, stmt.CacheTTL, func() (ret *unstructured.Unstructured, err error) {
+	var res *unstructured.Unstructured
+	// informer使用集群自身的凭证，模拟用户身份时直接查询API Server，由其鉴权
+	if stmt.FromCache && stmt.Impersonate == nil {
+		// 从informer缓存中读取
 		if namespaced {
 			if ns == "" {
 				ns = metav1.NamespaceDefault
 			}
-			ret, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
 		} else {
-			ret, err = stmt.Kubectl.DynamicClient().Resource(gvr).Get(ctx, name, metav1.GetOptions{})
+			ns = metav1.NamespaceNone
 		}
-		return
-	})
+		res, err = k.Informers().Get(ctx, gvr, ns, name)
+	} else {
+		cacheKey := getCacheKey(stmt)
+		if stmt.CacheTTL > 0 {
+			k.Tools().TrackCacheKey(gvr, cacheNamespace(stmt), name, cacheKey)
+		}
+		res, err = utils.GetOrSetCache(k.ClusterCache(), cacheKey, stmt.CacheTTL, func() (ret *unstructured.Unstructured, err error) {
+			if namespaced {
+				if ns == "" {
+					ns = metav1.NamespaceDefault
+				}
+				ret, err = k.DynamicClient().Resource(gvr).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
+			} else {
+				ret, err = k.DynamicClient().Resource(gvr).Get(ctx, name, metav1.GetOptions{})
+			}
+			return
+		})
+	}
 	if err != nil {
 		return err
 	}
@@ -42,28 +42,48 @@ func List(k *kom.Kubectl) error {
 	// 获取切片的元素类型
 	elemType := destValue.Elem().Type().Elem()
 
-	cacheKey := fmt.Sprintf("%s/%s/%s/%s", ns, gvr.Group, gvr.Resource, gvr.Version)
-	list, err := utils.GetOrSetCache(stmt.ClusterCache(), cacheKey, stmt.CacheTTL, func() (list *unstructured.UnstructuredList, err error) {
-		// TODO 获取列表改为使用Option,解决大数据量获取问题。
+	var list *unstructured.UnstructuredList
+	var err error
+	// informer使用集群自身的凭证，模拟用户身份时直接查询API Server，由其鉴权
+	if stmt.FromCache && stmt.Impersonate == nil {
+		// 从informer缓存中读取，缓存中保存的是全部命名空间的数据
 		if namespaced {
 			if stmt.AllNamespace || len(namespaceList) > 1 {
-				// 全部命名空间 或者  传入多个命名空间
-				// client-go 不支持跨命名空间查询，就全部查出来，后面再过滤
 				ns = metav1.NamespaceAll
-				list, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).List(ctx, listOptions)
-			} else {
-				// 不是全部，也没有传多个命名空间
-				if ns == "" {
-					ns = metav1.NamespaceDefault
-				}
-				list, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).List(ctx, listOptions)
+			} else if ns == "" {
+				ns = metav1.NamespaceDefault
 			}
 		} else {
-			// 集群级查询，不需要namespace
-			list, err = stmt.Kubectl.DynamicClient().Resource(gvr).List(ctx, listOptions)
+			ns = metav1.NamespaceNone
 		}
-		return
-	})
+		list, err = k.Informers().List(ctx, gvr, ns, listOptions)
+	} else {
+		cacheKey := listCacheKey(stmt, listOptions)
+		if stmt.CacheTTL > 0 {
+			k.Tools().TrackCacheKey(gvr, cacheNamespace(stmt), "", cacheKey)
+		}
+		list, err = utils.GetOrSetCache(stmt.ClusterCache(), cacheKey, stmt.CacheTTL, func() (list *unstructured.UnstructuredList, err error) {
+			// TODO 获取列表改为使用Option,解决大数据量获取问题。
+			if namespaced {
+				if stmt.AllNamespace || len(namespaceList) > 1 {
+					// 全部命名空间 或者  传入多个命名空间
+					// client-go 不支持跨命名空间查询，就全部查出来，后面再过滤
+					ns = metav1.NamespaceAll
+					list, err = k.DynamicClient().Resource(gvr).Namespace(ns).List(ctx, listOptions)
+				} else {
+					// 不是全部，也没有传多个命名空间
+					if ns == "" {
+						ns = metav1.NamespaceDefault
+					}
+					list, err = k.DynamicClient().Resource(gvr).Namespace(ns).List(ctx, listOptions)
+				}
+			} else {
+				// 集群级查询，不需要namespace
+				list, err = k.DynamicClient().Resource(gvr).List(ctx, listOptions)
+			}
+			return
+		})
+	}
 	if err != nil {
 		return err
 	}
@@ -8,6 +8,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 func Patch(k *kom.Kubectl) error {
@@ -21,6 +22,15 @@ func Patch(k *kom.Kubectl) error {
 	patchType := stmt.PatchType
 	patchData := stmt.PatchData
 
+	patchOptions := metav1.PatchOptions{
+		DryRun:       dryRun(stmt),
+		FieldManager: stmt.FieldManager,
+	}
+	// Force 仅对服务端应用有效
+	if patchType == types.ApplyPatchType {
+		patchOptions.Force = &stmt.ForceConflicts
+	}
+
 	var res *unstructured.Unstructured
 	var err error
 	if name == "" {
@@ -31,9 +41,9 @@ func Patch(k *kom.Kubectl) error {
 		if ns == "" {
 			ns = metav1.NamespaceDefault
 		}
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Patch(ctx, name, patchType, []byte(patchData), metav1.PatchOptions{})
+		res, err = k.DynamicClient().Resource(gvr).Namespace(ns).Patch(ctx, name, patchType, []byte(patchData), patchOptions)
 	} else {
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Patch(ctx, name, patchType, []byte(patchData), metav1.PatchOptions{})
+		res, err = k.DynamicClient().Resource(gvr).Patch(ctx, name, patchType, []byte(patchData), patchOptions)
 	}
 	if err != nil {
 		return err
@@ -42,9 +42,9 @@ func Watch(k *kom.Kubectl) error {
 		if ns == "" {
 			ns = metav1.NamespaceDefault
 		}
-		watcher, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Watch(ctx, listOptions)
+		watcher, err = k.DynamicClient().Resource(gvr).Namespace(ns).Watch(ctx, listOptions)
 	} else {
-		watcher, err = stmt.Kubectl.DynamicClient().Resource(gvr).Watch(ctx, listOptions)
+		watcher, err = k.DynamicClient().Resource(gvr).Watch(ctx, listOptions)
 	}
 	if err != nil {
 		return err
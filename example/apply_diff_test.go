@@ -0,0 +1,117 @@
+package example
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestApplyDiff(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: diff-test
+  namespace: default
+data:
+  key: value
+`
+	changed := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: diff-test
+  namespace: default
+data:
+  key: changed
+  added: value
+`
+	// 不存在时为创建，不修改集群
+	diffs := kom.DefaultCluster().Applier().Diff(yaml)
+	if err := diffs.Err(); err != nil {
+		t.Fatalf("diff error %v", err)
+	}
+	if diffs[0].Action != kom.ApplyActionCreated || diffs[0].Live != nil {
+		t.Errorf("diff action want created, got %s", diffs[0].Action)
+	}
+	var cm v1.ConfigMap
+	err := kom.DefaultCluster().Resource(&cm).Namespace("default").Name("diff-test").Get(&cm).Error
+	if err == nil {
+		t.Errorf("diff should not create configmap")
+	}
+
+	results := kom.DefaultCluster().Applier().Apply(yaml)
+	defer kom.DefaultCluster().Applier().Delete(yaml)
+	if err := results.Err(); err != nil {
+		t.Fatalf("apply error %v", err)
+	}
+
+	// 未变化时没有差异
+	diffs = kom.DefaultCluster().Applier().Diff(yaml)
+	if diffs[0].Action != kom.ApplyActionUnchanged || diffs[0].Diff != "" || len(diffs[0].Patch) != 0 {
+		t.Errorf("diff want unchanged, got %s %s %v", diffs[0].Action, diffs[0].Diff, diffs[0].Patch)
+	}
+
+	diffs = kom.DefaultCluster().Applier().Diff(changed)
+	d := diffs[0]
+	if d.Action != kom.ApplyActionUpdated {
+		t.Errorf("diff action want updated, got %s", d.Action)
+	}
+	if d.Live == nil || d.Live.GetName() != "diff-test" || d.Live.Object["data"].(map[string]interface{})["key"] != "value" {
+		t.Errorf("diff should return the live object, got %v", d.Live)
+	}
+	if !strings.Contains(d.Diff, "-  key: value") || !strings.Contains(d.Diff, "+  key: changed") {
+		t.Errorf("unexpected diff %s", d.Diff)
+	}
+	ops := map[string]string{}
+	for _, op := range d.Patch {
+		ops[op.Path] = op.Op
+	}
+	if ops["/data/key"] != "replace" || ops["/data/added"] != "add" {
+		t.Errorf("unexpected patch %v", d.Patch)
+	}
+	// JSON字段与 ApplyResult 一致
+	data, err := json.Marshal(d)
+	if err != nil || !strings.Contains(string(data), `"action":"updated"`) || strings.Contains(string(data), `"Error"`) {
+		t.Errorf("unexpected diff json %s error %v", string(data), err)
+	}
+	// 忽略的字段不出现在差异中
+	if strings.Contains(d.Diff, "resourceVersion") || strings.Contains(d.Diff, "managedFields") {
+		t.Errorf("diff should ignore noisy fields %s", d.Diff)
+	}
+
+	// 预览不修改集群
+	err = kom.DefaultCluster().Resource(&cm).Namespace("default").Name("diff-test").Get(&cm).Error
+	if err != nil {
+		t.Fatalf("get configmap error %v", err)
+	}
+	if cm.Data["key"] != "value" {
+		t.Errorf("diff should not update configmap, got %s", cm.Data["key"])
+	}
+}
+
+func TestApplyDiffGetError(t *testing.T) {
+	// 获取现有对象失败（非不存在）时，预览不能显示为创建
+	processor := kom.DefaultCluster().Callback().Get()
+	_ = processor.Before("kom:get").Register("test:forbidden", func(k *kom.Kubectl) error {
+		return apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, k.Statement.Name, errors.New("test"))
+	})
+	defer processor.Remove("test:forbidden")
+
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: diff-get-error
+  namespace: default
+data:
+  key: value
+`
+	diffs := kom.DefaultCluster().Applier().Diff(yaml)
+	if len(diffs) != 1 || diffs[0].Action != kom.ApplyActionFailed || !apierrors.IsForbidden(diffs[0].Error) {
+		t.Errorf("get error should fail the diff, got %v", diffs.Err())
+	}
+}
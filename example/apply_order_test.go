@@ -0,0 +1,65 @@
+package example
+
+import (
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+)
+
+func TestApplyDependencyOrder(t *testing.T) {
+	// 自定义资源及命名空间排在CRD之前，按依赖关系排序后仍可创建成功
+	yaml := `apiVersion: stable.example.com/v1
+kind: OrderTest
+metadata:
+  name: order-test
+  namespace: apply-order-test
+spec:
+  size: 1
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: order-test
+  namespace: apply-order-test
+data:
+  key: value
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: ordertests.stable.example.com
+spec:
+  group: stable.example.com
+  versions:
+    - name: v1
+      served: true
+      storage: true
+      schema:
+        openAPIV3Schema:
+          type: object
+          x-kubernetes-preserve-unknown-fields: true
+  scope: Namespaced
+  names:
+    plural: ordertests
+    singular: ordertest
+    kind: OrderTest
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: apply-order-test
+`
+	results := kom.DefaultCluster().Applier().Apply(yaml)
+	defer kom.DefaultCluster().Applier().Delete(yaml)
+
+	if err := results.Err(); err != nil {
+		t.Errorf("apply error %v", err)
+	}
+	// 结果顺序与YAML一致
+	kinds := []string{"OrderTest", "ConfigMap", "CustomResourceDefinition", "Namespace"}
+	for i, r := range results {
+		if r.GVK.Kind != kinds[i] {
+			t.Errorf("result %d should be %s, got %s", i, kinds[i], r.GVK.Kind)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package example
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestApplyPrune(t *testing.T) {
+	both := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: prune-test-keep
+  namespace: default
+data:
+  key: value
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: prune-test-remove
+  namespace: default
+data:
+  key: value
+`
+	keep := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: prune-test-keep
+  namespace: default
+data:
+  key: value
+`
+	opts := kom.PruneOptions{ApplySet: "prune-test"}
+	defer kom.DefaultCluster().Applier().Delete(both)
+
+	results := kom.DefaultCluster().Applier().WithPrune(opts).Apply(both)
+	if err := results.Err(); err != nil {
+		t.Fatalf("apply error %v", err)
+	}
+	var cm v1.ConfigMap
+	err := kom.DefaultCluster().Resource(&cm).Namespace("default").Name("prune-test-keep").Get(&cm).Error
+	if err != nil {
+		t.Fatalf("get configmap error %v", err)
+	}
+	if cm.Labels[kom.ApplySetLabel] != "prune-test" {
+		t.Errorf("apply set label want prune-test, got %s", cm.Labels[kom.ApplySetLabel])
+	}
+
+	// 预览不修改也不删除
+	opts.Preview = true
+	results = kom.DefaultCluster().Applier().WithPrune(opts).Apply(strings.Replace(keep, "key: value", "key: changed", 1))
+	if !hasPruned(results, "prune-test-remove") {
+		t.Errorf("preview should report prune-test-remove, got %v", results.Strings())
+	}
+	err = kom.DefaultCluster().Resource(&cm).Namespace("default").Name("prune-test-remove").Get(&cm).Error
+	if err != nil {
+		t.Errorf("preview should not delete configmap, get error %v", err)
+	}
+	err = kom.DefaultCluster().Resource(&cm).Namespace("default").Name("prune-test-keep").Get(&cm).Error
+	if err != nil || cm.Data["key"] != "value" {
+		t.Errorf("preview should not update configmap, got %v error %v", cm.Data, err)
+	}
+
+	// 实际清理
+	opts.Preview = false
+	results = kom.DefaultCluster().Applier().WithPrune(opts).Apply(keep)
+	if !hasPruned(results, "prune-test-remove") {
+		t.Errorf("prune should delete prune-test-remove, got %v", results.Strings())
+	}
+	if hasPruned(results, "prune-test-keep") {
+		t.Errorf("prune should keep prune-test-keep, got %v", results.Strings())
+	}
+}
+
+func TestApplyPruneInvalidApplySet(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: prune-test-invalid
+  namespace: default
+`
+	results := kom.DefaultCluster().Applier().WithPrune(kom.PruneOptions{}).Apply(yaml)
+	if results.Err() == nil {
+		t.Errorf("empty apply set should fail")
+	}
+}
+
+func TestApplyPruneClusterScopedNamespace(t *testing.T) {
+	// 集群级别的资源在YAML中填写了命名空间时，不能被误认为已不在YAML中
+	yaml := `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: prune-test-cluster-role
+  namespace: default
+rules: []
+`
+	opts := kom.PruneOptions{
+		ApplySet: "prune-test-cluster",
+		Kinds:    []schema.GroupVersionKind{{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}},
+	}
+	defer kom.DefaultCluster().Applier().Delete(yaml)
+
+	for i := 0; i < 2; i++ {
+		results := kom.DefaultCluster().Applier().WithPrune(opts).Apply(yaml)
+		if err := results.Err(); err != nil {
+			t.Fatalf("apply error %v", err)
+		}
+		if hasPruned(results, "prune-test-cluster-role") {
+			t.Fatalf("prune should keep prune-test-cluster-role, got %v", results.Strings())
+		}
+	}
+}
+
+func hasPruned(results kom.ApplyResults, name string) bool {
+	for _, r := range results {
+		if r.Action == kom.ApplyActionPruned && r.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package example
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	"github.com/weibaohui/kom/plugins/audit"
+	v1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type memorySink struct {
+	mu      sync.Mutex
+	records []*audit.Record
+}
+
+func (s *memorySink) Write(record *audit.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+	return nil
+}
+
+func TestAuditPatch(t *testing.T) {
+	sink := &memorySink{}
+	audit.Install(audit.Options{Sink: sink, Diff: true, Operations: []string{"patch"}})
+	defer kom.DefaultCluster().Callback().Patch().RemoveHook("kom:audit")
+
+	var item v1.Deployment
+	ctx := audit.WithCaller(context.Background(), "audit-test")
+	err := kom.DefaultCluster().WithContext(ctx).Resource(&item).
+		Namespace("default").
+		Name("nginx").
+		Patch(&item, types.StrategicMergePatchType, `{"metadata":{"labels":{"audit":"test"}}}`).Error
+	if err != nil {
+		t.Errorf("Patch error %v", err)
+		return
+	}
+	if len(sink.records) == 0 {
+		t.Errorf("patch should be audited")
+		return
+	}
+	record := sink.records[len(sink.records)-1]
+	if record.Caller != "audit-test" || record.Operation != "patch" || record.Name != "nginx" || !record.Success {
+		t.Errorf("unexpected audit record %+v", record)
+	}
+	t.Logf("audit diff %s", record.Diff)
+}
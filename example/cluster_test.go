@@ -0,0 +1,309 @@
+package example
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestConcurrentRegisterSameID(t *testing.T) {
+	config := kom.DefaultCluster().RestConfig()
+	id := "concurrent-register"
+	defer kom.Clusters().RemoveClusterById(id)
+
+	var wg sync.WaitGroup
+	results := make([]*kom.Kubectl, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			k, err := kom.Clusters().RegisterByConfigWithID(config, id, kom.RegisterOptions{DisableDiscoveryWatch: true})
+			if err != nil {
+				t.Errorf("RegisterByConfigWithID error %v", err)
+				return
+			}
+			results[i] = k
+		}(i)
+		// 注册的同时读取集群列表
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range kom.Clusters().AllClusters() {
+			}
+		}()
+	}
+	wg.Wait()
+	for _, k := range results {
+		if k != results[0] {
+			t.Errorf("concurrent register of the same id should return the same instance")
+		}
+	}
+}
+
+func TestAllClustersSnapshot(t *testing.T) {
+	clusters := kom.Clusters().AllClusters()
+	delete(clusters, "default")
+	if kom.Clusters().GetClusterById("default") == nil {
+		t.Errorf("modifying AllClusters result should not remove registered cluster")
+	}
+}
+
+func TestRegisterWithOptions(t *testing.T) {
+	config := kom.DefaultCluster().RestConfig()
+	id := "register-options"
+	defer kom.Clusters().RemoveClusterById(id)
+
+	k, err := kom.Clusters().RegisterByConfigWithID(config, id, kom.RegisterOptions{
+		QPS:                   10,
+		Burst:                 20,
+		UserAgent:             "kom-test",
+		DisableDiscoveryWatch: true,
+	})
+	if err != nil {
+		t.Errorf("RegisterByConfigWithID error %v", err)
+		return
+	}
+	if k.RestConfig().QPS != 10 || k.RestConfig().Burst != 20 || k.RestConfig().UserAgent != "kom-test" {
+		t.Errorf("register options should be applied to config")
+	}
+	if config.UserAgent == "kom-test" {
+		t.Errorf("register options should not modify the original config")
+	}
+	if k.Status().Docs() == nil {
+		t.Errorf("Docs should be loaded on first use")
+	}
+}
+
+func TestRegisterUnreachableCluster(t *testing.T) {
+	id := "unreachable"
+	defer kom.Clusters().RemoveClusterById(id)
+
+	config := &rest.Config{Host: "https://127.0.0.1:1", Timeout: time.Second}
+	k, err := kom.Clusters().RegisterByConfigWithID(config, id, kom.RegisterOptions{DisableDiscoveryWatch: true})
+	if err != nil {
+		t.Errorf("register unreachable cluster should succeed, got %v", err)
+		return
+	}
+	if len(k.Status().APIResources()) != 0 {
+		t.Errorf("unreachable cluster should have no api resources")
+	}
+	if k.Status().State() != kom.ClusterStateDisconnected {
+		t.Errorf("unreachable cluster state should be disconnected, got %s", k.Status().State())
+	}
+	if kom.DefaultCluster().Status().State() != kom.ClusterStateConnected {
+		t.Errorf("default cluster state should be connected, got %s", kom.DefaultCluster().Status().State())
+	}
+}
+
+func TestUnreachableClusterDiscoveryBackoff(t *testing.T) {
+	id := "unreachable-backoff"
+	defer kom.Clusters().RemoveClusterById(id)
+
+	config := &rest.Config{Host: "https://127.0.0.1:1", Timeout: time.Second}
+	k, err := kom.Clusters().RegisterByConfigWithID(config, id, kom.RegisterOptions{
+		DisableDiscoveryWatch: true,
+		DisableHealthCheck:    true,
+	})
+	if err != nil {
+		t.Errorf("register unreachable cluster should succeed, got %v", err)
+		return
+	}
+	_ = k.Status().APIResources()
+	// 重试间隔内不再访问API Server，直接返回
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		_ = k.Status().APIResources()
+	}
+	if cost := time.Since(start); cost > 500*time.Millisecond {
+		t.Errorf("discovery should back off after failure, 10 calls cost %v", cost)
+	}
+}
+
+func TestRegisterByKubeconfigBytes(t *testing.T) {
+	data, err := os.ReadFile(kubeconfigPath())
+	if err != nil {
+		t.Skipf("read kubeconfig error %v", err)
+	}
+	id := "kubeconfig-bytes"
+	defer kom.Clusters().RemoveClusterById(id)
+	k, err := kom.Clusters().RegisterByKubeconfigBytes(data, id, kom.RegisterOptions{DisableDiscoveryWatch: true})
+	if err != nil {
+		t.Errorf("RegisterByKubeconfigBytes error %v", err)
+		return
+	}
+	if k.RestConfig().Host != kom.DefaultCluster().RestConfig().Host {
+		t.Errorf("RegisterByKubeconfigBytes host should be %s, got %s", kom.DefaultCluster().RestConfig().Host, k.RestConfig().Host)
+	}
+}
+
+func TestRegisterAllContexts(t *testing.T) {
+	path := kubeconfigPath()
+	kubeconfig, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		t.Skipf("load kubeconfig error %v", err)
+	}
+	clusters, err := kom.Clusters().RegisterAllContexts(path, kom.RegisterOptions{DisableDiscoveryWatch: true})
+	if err != nil {
+		t.Errorf("RegisterAllContexts error %v", err)
+	}
+	for name := range kubeconfig.Contexts {
+		if clusters[name] == nil || kom.Clusters().GetClusterById(name) == nil {
+			t.Errorf("context %s should be registered", name)
+		}
+		kom.Clusters().RemoveClusterById(name)
+	}
+}
+
+func TestClusterHealth(t *testing.T) {
+	health := kom.Clusters().GetClusterById("default").CheckHealth()
+	if health.State != kom.ClusterStateConnected {
+		t.Errorf("default cluster should be connected, got %s %s", health.State, health.Error)
+	}
+	if health.LastSeen.IsZero() || health.Latency <= 0 {
+		t.Errorf("health check should record last seen and latency")
+	}
+	t.Logf("health %+v", kom.DefaultCluster().Status().Health())
+}
+
+func TestClusterHealthReinitialize(t *testing.T) {
+	id := "health-reinitialize"
+	defer kom.Clusters().RemoveClusterById(id)
+	_, err := kom.Clusters().RegisterByConfigWithID(kom.DefaultCluster().RestConfig(), id, kom.RegisterOptions{
+		DisableDiscoveryWatch: true,
+		DisableHealthCheck:    true,
+	})
+	if err != nil {
+		t.Errorf("RegisterByConfigWithID error %v", err)
+		return
+	}
+	cluster := kom.Clusters().GetClusterById(id)
+	// 连续检查只触发一次重新获取，获取完成后标记为 connected
+	for i := 0; i < 10; i++ {
+		cluster.CheckHealth()
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for cluster.State() != kom.ClusterStateConnected && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if cluster.State() != kom.ClusterStateConnected {
+		t.Errorf("cluster should be connected after reinitialize, got %s", cluster.State())
+	}
+	if len(cluster.Kubectl.Status().APIResources()) == 0 {
+		t.Errorf("cluster should have api resources after reinitialize")
+	}
+	if cluster.Kubectl.Status().ServerVersion() == nil {
+		t.Errorf("cluster should have server version after reinitialize")
+	}
+}
+
+func TestClusterSelect(t *testing.T) {
+	config := kom.DefaultCluster().RestConfig()
+	ids := []string{"select-prod-1", "select-prod-2"}
+	for _, id := range ids {
+		_, err := kom.Clusters().RegisterByConfigWithID(config, id, kom.RegisterOptions{
+			Tags:                  map[string]string{"env": "prod"},
+			DisableDiscoveryWatch: true,
+		})
+		if err != nil {
+			t.Errorf("RegisterByConfigWithID error %v", err)
+			return
+		}
+		defer kom.Clusters().RemoveClusterById(id)
+	}
+
+	selection := kom.Clusters().Select("env=prod")
+	if len(selection.IDs()) != len(ids) {
+		t.Errorf("select env=prod should return %d clusters, got %v", len(ids), selection.IDs())
+	}
+	results := selection.Concurrency(1).
+		Resource(&corev1.Pod{}).
+		Namespace("default").
+		List(&[]corev1.Pod{})
+	for _, r := range results {
+		if r.Error != nil {
+			t.Errorf("cluster %s list error %v", r.ClusterID, r.Error)
+			continue
+		}
+		pods := r.Result.(*[]corev1.Pod)
+		t.Logf("cluster %s has %d pods", r.ClusterID, len(*pods))
+	}
+
+	// dest 不是指针时各集群会写入同一个对象，直接返回错误
+	results = selection.Resource(&corev1.Pod{}).Namespace("default").List([]corev1.Pod{})
+	for _, r := range results {
+		if r.Error == nil {
+			t.Errorf("cluster %s list with non-pointer dest should fail", r.ClusterID)
+		}
+	}
+
+	// 修改返回的标签不影响集群
+	kom.Clusters().GetClusterById(ids[0]).Tags()["env"] = "dev"
+	if kom.Clusters().GetClusterById(ids[0]).Tags()["env"] != "prod" {
+		t.Errorf("modify returned tags should not change cluster tags")
+	}
+
+	results = kom.Clusters().Select("env in (").Delete()
+	if len(results) != 1 || results[0].Error == nil {
+		t.Errorf("invalid selector should return error")
+	}
+}
+
+func TestClusterStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clusters.yaml")
+	store, err := kom.NewFileClusterStore(path, []byte("0123456789abcdef"))
+	if err != nil {
+		t.Errorf("NewFileClusterStore error %v", err)
+		return
+	}
+	kom.Clusters().SetStore(store)
+	defer kom.Clusters().SetStore(nil)
+
+	id := "store-cluster"
+	_, err = kom.Clusters().RegisterByPathWithID(kubeconfigPath(), id, kom.RegisterOptions{
+		Tags:                  map[string]string{"env": "test"},
+		DisableDiscoveryWatch: true,
+	})
+	if err != nil {
+		t.Errorf("RegisterByPathWithID error %v", err)
+		return
+	}
+	records, err := store.Load()
+	if err != nil || len(records) != 1 || records[0].ID != id || records[0].Options.Tags["env"] != "test" {
+		t.Errorf("registered cluster should be saved to store, got %v %v", records, err)
+		return
+	}
+	// 只保存当前上下文
+	saved, err := clientcmd.Load([]byte(records[0].Kubeconfig))
+	if err != nil || len(saved.Contexts) != 1 || len(saved.AuthInfos) > 1 {
+		t.Errorf("store should only save the current context, got %v %v", saved, err)
+	}
+
+	// 删除后重新从存储中加载
+	kom.Clusters().RemoveClusterById(id)
+	if err := store.Save(records[0]); err != nil {
+		t.Errorf("save record error %v", err)
+	}
+	before, _ := os.ReadFile(path)
+	if err := kom.Clusters().LoadFromStore(); err != nil {
+		t.Errorf("LoadFromStore error %v", err)
+	}
+	// 加载时不写回存储
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Errorf("LoadFromStore should not rewrite the store")
+	}
+	if kom.Clusters().GetClusterById(id) == nil {
+		t.Errorf("cluster should be registered from store")
+	}
+	kom.Clusters().RemoveClusterById(id)
+	records, _ = store.Load()
+	if len(records) != 0 {
+		t.Errorf("removed cluster should be deleted from store")
+	}
+}
@@ -12,10 +12,15 @@ import (
 func Connect() {
 	callbacks.RegisterInit()
 
+	_, _ = kom.Clusters().RegisterByPathWithID(kubeconfigPath(), "default")
+	kom.Clusters().Show()
+}
+
+// kubeconfigPath 默认kubeconfig路径，优先使用KUBECONFIG环境变量
+func kubeconfigPath() string {
 	defaultKubeConfig := os.Getenv("KUBECONFIG")
 	if defaultKubeConfig == "" {
 		defaultKubeConfig = filepath.Join(homedir.HomeDir(), ".kube", "config")
 	}
-	_, _ = kom.Clusters().RegisterByPathWithID(defaultKubeConfig, "default")
-	kom.Clusters().Show()
+	return defaultKubeConfig
 }
@@ -0,0 +1,25 @@
+package example
+
+import (
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+)
+
+func TestRefreshDiscovery(t *testing.T) {
+	err := kom.DefaultCluster().Status().RefreshDiscovery()
+	if err != nil {
+		t.Errorf("RefreshDiscovery error %v", err)
+		return
+	}
+	if len(kom.DefaultCluster().Status().APIResources()) == 0 {
+		t.Errorf("APIResources should not be empty after refresh")
+	}
+	found := false
+	for _, crd := range kom.DefaultCluster().Status().CRDList() {
+		if crd.GetName() == "crontabs.stable.example.com" {
+			found = true
+		}
+	}
+	t.Logf("CRD crontabs.stable.example.com found %v", found)
+}
@@ -1,9 +1,11 @@
 package example
 
 import (
+	"sync"
 	"testing"
 
 	"github.com/weibaohui/kom/kom"
+	komdoc "github.com/weibaohui/kom/kom/doc"
 	"github.com/weibaohui/kom/utils"
 )
 
@@ -12,3 +14,29 @@ func TestDoc(t *testing.T) {
 	pc := docs.FetchByGVK("v1", "Pod")
 	t.Logf(utils.ToJSON(pc))
 }
+
+func TestDocInitTreesConcurrently(t *testing.T) {
+	schema, err := kom.DefaultCluster().Client().Discovery().OpenAPISchema()
+	if err != nil {
+		t.Skipf("get openapi schema error %v", err)
+	}
+	// 多个集群同时加载文档时，互不影响
+	results := make([]*komdoc.Docs, 3)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = komdoc.InitTrees(schema)
+		}(i)
+	}
+	wg.Wait()
+	for _, docs := range results {
+		if len(docs.Trees) != len(results[0].Trees) {
+			t.Errorf("docs trees should be %d, got %d", len(results[0].Trees), len(docs.Trees))
+		}
+		if docs.FetchByGVK("v1", "Pod") == nil {
+			t.Errorf("docs should contain Pod")
+		}
+	}
+}
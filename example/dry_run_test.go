@@ -0,0 +1,123 @@
+package example
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestDryRunCreate(t *testing.T) {
+	item := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "dry-run-cm",
+			Namespace: "default",
+		},
+		Data: map[string]string{"key": "value"},
+	}
+	err := kom.DefaultCluster().Resource(&item).DryRun().Create(&item).Error
+	if err != nil {
+		t.Errorf("DryRun Create error %v", err)
+		return
+	}
+	if item.UID == "" {
+		t.Errorf("dry run create should return the object produced by server")
+	}
+
+	var cm corev1.ConfigMap
+	err = kom.DefaultCluster().Resource(&cm).Namespace("default").Name("dry-run-cm").Get(&cm).Error
+	if err == nil {
+		t.Errorf("dry run create should not persist the object")
+	}
+}
+
+func TestDryRunPatch(t *testing.T) {
+	var pod corev1.Pod
+	err := kom.DefaultCluster().Resource(&pod).Namespace("default").Name("random").
+		DryRun().
+		Patch(&pod, types.MergePatchType, `{"metadata":{"labels":{"dry-run":"true"}}}`).Error
+	if err != nil {
+		t.Errorf("DryRun Patch error %v", err)
+		return
+	}
+	if pod.Labels["dry-run"] != "true" {
+		t.Errorf("dry run patch should return the patched object")
+	}
+
+	var current corev1.Pod
+	err = kom.DefaultCluster().Resource(&current).Namespace("default").Name("random").Get(&current).Error
+	if err != nil {
+		t.Errorf("Get error %v", err)
+		return
+	}
+	if _, ok := current.Labels["dry-run"]; ok {
+		t.Errorf("dry run patch should not persist the label")
+	}
+}
+
+func TestDryRunApply(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: dry-run-apply
+  namespace: default
+data:
+  key: value
+`
+	results := kom.DefaultCluster().DryRun().Applier().Apply(yaml)
+	for _, r := range results {
+		if r.Error != nil || !r.DryRun || !strings.HasSuffix(r.String(), "(server dry run)") {
+			t.Errorf("unexpected apply result %s", r)
+		}
+	}
+}
+
+func TestDryRunDrain(t *testing.T) {
+	var nodes []corev1.Node
+	err := kom.DefaultCluster().Resource(&corev1.Node{}).List(&nodes).Error
+	if err != nil || len(nodes) == 0 {
+		t.Skipf("list node error %v", err)
+	}
+	name := nodes[0].Name
+	listPods := func() map[types.UID]bool {
+		var pods []corev1.Pod
+		err := kom.DefaultCluster().Resource(&corev1.Pod{}).AllNamespace().
+			WithFieldSelector("spec.nodeName=" + name).
+			List(&pods).Error
+		if err != nil {
+			t.Fatalf("list pods error %v", err)
+		}
+		result := map[types.UID]bool{}
+		for _, p := range pods {
+			if p.DeletionTimestamp == nil {
+				result[p.UID] = true
+			}
+		}
+		return result
+	}
+	before := listPods()
+
+	err = kom.DefaultCluster().Resource(&corev1.Node{}).Name(name).DryRun().Ctl().Node().Drain()
+	if err != nil {
+		t.Errorf("DryRun Drain error %v", err)
+	}
+
+	// 节点仍可调度，Pod均未被驱逐
+	var node corev1.Node
+	err = kom.DefaultCluster().Resource(&node).Name(name).Get(&node).Error
+	if err != nil {
+		t.Fatalf("get node error %v", err)
+	}
+	if node.Spec.Unschedulable {
+		t.Errorf("dry run drain should not cordon node %s", name)
+	}
+	after := listPods()
+	for uid := range before {
+		if !after[uid] {
+			t.Errorf("dry run drain should not evict pod %s", uid)
+		}
+	}
+}
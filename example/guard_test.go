@@ -0,0 +1,49 @@
+package example
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	"github.com/weibaohui/kom/plugins/guard"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGuardDenyDelete(t *testing.T) {
+	guard.Install(guard.DenyDelete("Namespace"), guard.MaxBulkDelete(1))
+	defer kom.DefaultCluster().Callback().Delete().RemoveHook("kom:guard")
+
+	var ns corev1.Namespace
+	err := kom.DefaultCluster().Resource(&ns).Name("default").Delete().Error
+	var denied *guard.ErrPolicyDenied
+	if !errors.As(err, &denied) {
+		t.Errorf("delete namespace should be denied, got %v", err)
+		return
+	}
+	if denied.Rule != "deny-delete:Namespace" {
+		t.Errorf("unexpected rule %s", denied.Rule)
+	}
+
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: guard-test-1
+  namespace: default
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: guard-test-2
+  namespace: default
+`
+	results := kom.DefaultCluster().Applier().Delete(yaml)
+	if len(results) != 2 {
+		t.Errorf("expected 2 results, got %d", len(results))
+	}
+	for _, r := range results {
+		if !guard.IsPolicyDenied(r.Error) || !strings.Contains(r.String(), "max-bulk-delete:1") {
+			t.Errorf("bulk delete should be denied, got %s", r)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package example
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestAfterHookOnError(t *testing.T) {
+	var result *kom.ExecResult
+	var errorCalled bool
+	processor := kom.DefaultCluster().Callback().Get()
+	processor.AfterHook("test:after", func(k *kom.Kubectl, r *kom.ExecResult) {
+		result = r
+	})
+	processor.OnError("test:error", func(k *kom.Kubectl, r *kom.ExecResult) {
+		errorCalled = true
+	})
+	defer processor.RemoveHook("test:after")
+	defer processor.RemoveHook("test:error")
+
+	var pod corev1.Pod
+	err := kom.DefaultCluster().Resource(&pod).Namespace("default").Name("not-exists-pod").Get(&pod).Error
+	if err == nil {
+		t.Errorf("get not exists pod should return error")
+		return
+	}
+	if result == nil || result.Error == nil || result.Operation != "get" {
+		t.Errorf("after hook should receive the error, got %+v", result)
+	}
+	if !errorCalled {
+		t.Errorf("error hook should be called")
+	}
+}
+
+func TestBeforeHookAbort(t *testing.T) {
+	processor := kom.DefaultCluster().Callback().List()
+	processor.BeforeHook("test:deny", func(k *kom.Kubectl) error {
+		return fmt.Errorf("denied")
+	})
+	var afterErr error
+	processor.AfterHook("test:after", func(k *kom.Kubectl, r *kom.ExecResult) {
+		afterErr = r.Error
+	})
+
+	var list []corev1.Pod
+	err := kom.DefaultCluster().Resource(&corev1.Pod{}).Namespace("default").List(&list).Error
+	processor.RemoveHook("test:deny")
+	processor.RemoveHook("test:after")
+	if err == nil || err.Error() != "denied" {
+		t.Errorf("before hook error should abort list, got %v", err)
+	}
+	if afterErr == nil {
+		t.Errorf("after hook should run when before hook fails")
+	}
+
+	err = kom.DefaultCluster().Resource(&corev1.Pod{}).Namespace("default").List(&list).Error
+	if err != nil {
+		t.Errorf("list after remove hook error %v", err)
+	}
+}
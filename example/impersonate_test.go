@@ -0,0 +1,85 @@
+package example
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestImpersonateForbidden(t *testing.T) {
+	var list []corev1.Pod
+	err := kom.DefaultCluster().As("kom-test-nobody").
+		Resource(&corev1.Pod{}).
+		Namespace("default").
+		List(&list).Error
+	if !apierrors.IsForbidden(err) {
+		t.Errorf("user without permission should be forbidden, got %v", err)
+	}
+}
+
+func TestImpersonateCacheIsolated(t *testing.T) {
+	var list []corev1.Pod
+	err := kom.DefaultCluster().Resource(&corev1.Pod{}).
+		Namespace("default").
+		WithCache(5 * time.Second).
+		List(&list).Error
+	if err != nil {
+		t.Errorf("List error %v", err)
+		return
+	}
+	// 管理员的缓存不应被无权限的用户读到
+	err = kom.DefaultCluster().As("kom-test-nobody").
+		Resource(&corev1.Pod{}).
+		Namespace("default").
+		WithCache(5 * time.Second).
+		List(&list).Error
+	if !apierrors.IsForbidden(err) {
+		t.Errorf("cached result should not be shared between identities, got %v", err)
+	}
+}
+
+func TestImpersonateSystemMasters(t *testing.T) {
+	var list []corev1.Pod
+	err := kom.DefaultCluster().As("kom-test-admin", "system:masters").
+		Resource(&corev1.Pod{}).
+		Namespace("default").
+		FromCache().
+		List(&list).Error
+	if err != nil {
+		t.Errorf("system:masters should be allowed, got %v", err)
+	}
+}
+
+func TestImpersonateDescribeForbidden(t *testing.T) {
+	var nodes []corev1.Node
+	err := kom.DefaultCluster().Resource(&corev1.Node{}).List(&nodes).Error
+	if err != nil || len(nodes) == 0 {
+		t.Skipf("list node error %v", err)
+	}
+	// 描述器以模拟的身份访问，不能以管理员身份读取
+	var result []byte
+	err = kom.DefaultCluster().As("kom-test-nobody").
+		Resource(&corev1.Node{}).
+		Name(nodes[0].Name).
+		Describe(&result).Error
+	if err == nil {
+		t.Errorf("describe as user without permission should fail, got %s", string(result))
+	}
+}
+
+func TestImpersonateRefreshDiscovery(t *testing.T) {
+	// 以无权限用户刷新发现结果，仍使用集群自身的凭据，不影响集群状态
+	err := kom.DefaultCluster().As("kom-test-nobody").Status().RefreshDiscovery()
+	if err != nil {
+		t.Errorf("refresh discovery as user without permission error %v", err)
+	}
+	if kom.DefaultCluster().Status().State() != kom.ClusterStateConnected {
+		t.Errorf("cluster state should stay connected, got %s", kom.DefaultCluster().Status().State())
+	}
+	if len(kom.DefaultCluster().Status().APIResources()) == 0 {
+		t.Errorf("api resources should not be narrowed by the impersonated user")
+	}
+}
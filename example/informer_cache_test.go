@@ -0,0 +1,83 @@
+package example
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestFromCacheList(t *testing.T) {
+	var list []corev1.Pod
+	err := kom.DefaultCluster().Resource(&corev1.Pod{}).
+		Namespace("default").
+		FromCache().
+		WithLabelSelector("app=random").
+		List(&list).Error
+	if err != nil {
+		t.Errorf("FromCache List error %v", err)
+		return
+	}
+	if len(list) == 0 {
+		t.Errorf("FromCache List should return random pod")
+	}
+	for _, p := range list {
+		t.Logf("FromCache List Item %s/%s", p.Namespace, p.Name)
+	}
+}
+func TestFromCacheWhere(t *testing.T) {
+	var list []corev1.Pod
+	err := kom.DefaultCluster().Resource(&corev1.Pod{}).
+		AllNamespace().
+		FromCache().
+		Where("metadata.name = 'random'").
+		List(&list).Error
+	if err != nil {
+		t.Errorf("FromCache List error %v", err)
+		return
+	}
+	if len(list) != 1 {
+		t.Errorf("FromCache Where should return 1 pod, got %d", len(list))
+	}
+}
+func TestFromCacheGet(t *testing.T) {
+	var pod corev1.Pod
+	err := kom.DefaultCluster().Resource(&pod).
+		Namespace("default").
+		Name("random").
+		FromCache().
+		Get(&pod).Error
+	if err != nil {
+		t.Errorf("FromCache Get error %v", err)
+		return
+	}
+	if pod.Name != "random" {
+		t.Errorf("FromCache Get name should be random, got %s", pod.Name)
+	}
+}
+
+func TestFromCacheSyncFailure(t *testing.T) {
+	// 同步失败的informer被停止，重试间隔内直接返回错误，不再等待同步超时
+	gvr := schema.GroupVersionResource{Group: "kom.example.io", Version: "v1", Resource: "notexists"}
+	informers := kom.DefaultCluster().Informers()
+	defer informers.Stop(gvr)
+
+	start := time.Now()
+	if _, err := informers.Get(context.TODO(), gvr, "default", "random"); err == nil {
+		t.Fatalf("informer of missing resource should fail")
+	}
+	for _, active := range informers.ActiveGVRs() {
+		if active == gvr {
+			t.Errorf("failed informer should be removed")
+		}
+	}
+	if _, err := informers.Get(context.TODO(), gvr, "default", "random"); err == nil {
+		t.Errorf("informer should fail again within retry interval")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("failed informer should not wait for sync timeout, took %s", elapsed)
+	}
+}
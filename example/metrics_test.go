@@ -0,0 +1,44 @@
+package example
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestMetrics(t *testing.T) {
+	var pod corev1.Pod
+	_ = kom.DefaultCluster().Resource(&pod).Namespace("default").Name("not-exists-pod").Get(&pod).Error
+
+	found := false
+	for _, m := range kom.Metrics().Operations() {
+		if m.Cluster == kom.DefaultCluster().ID && m.GVR == "v1/pods" && m.Operation == "get" && m.Outcome == kom.OutcomeError {
+			found = m.Count > 0
+		}
+	}
+	if !found {
+		t.Errorf("failed get should be counted")
+	}
+
+	server := httptest.NewServer(kom.Metrics())
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Errorf("get metrics error %v", err)
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	expected := fmt.Sprintf(`kom_operations_total{cluster="%s",gvr="v1/pods",operation="get",outcome="error"}`, kom.DefaultCluster().ID)
+	if !strings.Contains(string(body), expected) {
+		t.Errorf("metrics output should contain %s", expected)
+	}
+	if !strings.Contains(string(body), "# TYPE kom_operation_duration_seconds histogram") {
+		t.Errorf("metrics output should contain duration histogram")
+	}
+}
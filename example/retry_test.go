@@ -0,0 +1,164 @@
+package example
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weibaohui/kom/kom"
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestWithRetryTransientError(t *testing.T) {
+	// 前两次返回503，模拟服务端暂时不可用
+	attempts := 0
+	processor := kom.DefaultCluster().Callback().Patch()
+	_ = processor.Before("kom:patch").Register("test:unavailable", func(k *kom.Kubectl) error {
+		attempts++
+		if attempts <= 2 {
+			return apierrors.NewServiceUnavailable("test")
+		}
+		return nil
+	})
+	defer processor.Remove("test:unavailable")
+
+	var item v1.Deployment
+	err := kom.DefaultCluster().Resource(&item).Namespace("default").Name("nginx").
+		WithRetry(kom.RetryPolicy{MaxRetries: 3, InitialInterval: 10 * time.Millisecond}).
+		Patch(&item, types.MergePatchType, `{"metadata":{"labels":{"retry":"test"}}}`).Error
+	if err != nil {
+		t.Errorf("Patch should succeed after retry, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestGetAndUpdateConflict(t *testing.T) {
+	// 第一次更新返回冲突
+	var once sync.Once
+	processor := kom.DefaultCluster().Callback().Update()
+	_ = processor.Before("kom:update").Register("test:conflict", func(k *kom.Kubectl) error {
+		var err error
+		once.Do(func() {
+			err = apierrors.NewConflict(schema.GroupResource{Group: "apps", Resource: "deployments"}, "nginx", fmt.Errorf("test"))
+		})
+		return err
+	})
+	defer processor.Remove("test:conflict")
+
+	var item v1.Deployment
+	mutations := 0
+	err := kom.DefaultCluster().Resource(&item).Namespace("default").Name("nginx").
+		GetAndUpdate(&item, func(obj interface{}) error {
+			mutations++
+			if item.Labels == nil {
+				item.Labels = map[string]string{}
+			}
+			item.Labels["get-and-update"] = "test"
+			return nil
+		}).Error
+	if err != nil {
+		t.Errorf("GetAndUpdate error %v", err)
+	}
+	if mutations != 2 {
+		t.Errorf("expected mutate to be called twice, got %d", mutations)
+	}
+}
+
+func TestRetrySkipsGenerateNameCreate(t *testing.T) {
+	// 首次请求超时，对象可能已创建，未指定名称时不重试
+	attempts := 0
+	processor := kom.DefaultCluster().Callback().Create()
+	_ = processor.Before("kom:create").Register("test:timeout", func(k *kom.Kubectl) error {
+		attempts++
+		return apierrors.NewTimeoutError("test", 1)
+	})
+	defer processor.Remove("test:timeout")
+
+	item := corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{GenerateName: "retry-", Namespace: "default"}}
+	err := kom.DefaultCluster().Resource(&item).
+		WithRetry(kom.RetryPolicy{MaxRetries: 3, InitialInterval: 10 * time.Millisecond}).
+		Create(&item).Error
+	if err == nil {
+		t.Errorf("Create should return the timeout error")
+	}
+	if attempts != 1 {
+		t.Errorf("create with generateName should not be retried, got %d attempts", attempts)
+	}
+}
+
+func TestRetrySkipsPreconditionConflict(t *testing.T) {
+	attempts := 0
+	processor := kom.DefaultCluster().Callback().Patch()
+	_ = processor.Before("kom:patch").Register("test:conflict", func(k *kom.Kubectl) error {
+		attempts++
+		return apierrors.NewConflict(schema.GroupResource{Group: "apps", Resource: "deployments"}, "nginx", fmt.Errorf("test"))
+	})
+	defer processor.Remove("test:conflict")
+
+	var item v1.Deployment
+	err := kom.DefaultCluster().Resource(&item).Namespace("default").Name("nginx").
+		WithRetry(kom.RetryPolicy{MaxRetries: 3, InitialInterval: 10 * time.Millisecond}).
+		Patch(&item, types.MergePatchType, `{"metadata":{"resourceVersion":"1","labels":{"retry":"test"}}}`).Error
+	if !apierrors.IsConflict(err) {
+		t.Errorf("Patch should return the conflict, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("patch with resourceVersion precondition should not be retried, got %d attempts", attempts)
+	}
+}
+
+func TestFieldManagerConflictNotRetryable(t *testing.T) {
+	err := &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status: metav1.StatusFailure,
+		Code:   409,
+		Reason: metav1.StatusReasonConflict,
+		Details: &metav1.StatusDetails{
+			Causes: []metav1.StatusCause{{
+				Type:    metav1.CauseTypeFieldManagerConflict,
+				Message: `conflict with "kubectl"`,
+				Field:   ".spec.replicas",
+			}},
+		},
+	}}
+	if kom.IsRetryableError(err) {
+		t.Errorf("field manager conflict should not be retryable")
+	}
+	conflict := apierrors.NewConflict(schema.GroupResource{Group: "apps", Resource: "deployments"}, "nginx", fmt.Errorf("test"))
+	if !kom.IsRetryableError(conflict) {
+		t.Errorf("plain conflict should be retryable")
+	}
+}
+
+func TestRetryStopsAfterMutation(t *testing.T) {
+	// 变更请求成功后，之后的callback失败时不重试，变更不会重复提交
+	sent := 0
+	processor := kom.DefaultCluster().Callback().Patch()
+	_ = processor.Before("kom:patch").Register("test:count", func(k *kom.Kubectl) error {
+		sent++
+		return nil
+	})
+	_ = processor.After("kom:patch").Register("test:unavailable", func(k *kom.Kubectl) error {
+		return apierrors.NewServiceUnavailable("test")
+	})
+	defer processor.Remove("test:count")
+	defer processor.Remove("test:unavailable")
+
+	var item v1.Deployment
+	err := kom.DefaultCluster().Resource(&item).Namespace("default").Name("nginx").
+		WithRetry(kom.RetryPolicy{MaxRetries: 3, InitialInterval: 10 * time.Millisecond}).
+		Patch(&item, types.MergePatchType, `{"metadata":{"labels":{"retry":"test"}}}`).Error
+	if !apierrors.IsServiceUnavailable(err) {
+		t.Errorf("Patch should return the callback error, got %v", err)
+	}
+	if sent != 1 {
+		t.Errorf("patch should be sent once, got %d", sent)
+	}
+}
@@ -0,0 +1,81 @@
+package example
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestApplyServerSide(t *testing.T) {
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ssa-test
+  namespace: default
+data:
+  key: value
+`
+	results := kom.DefaultCluster().Applier().ApplyServerSide(yaml, "kom-test", false)
+	if len(results) != 1 || results[0].Action != kom.ApplyActionCreated {
+		t.Errorf("first server side apply should create, got %v", results)
+	}
+	defer kom.DefaultCluster().Applier().Delete(yaml)
+
+	results = kom.DefaultCluster().Applier().ApplyServerSide(yaml, "kom-test", false)
+	if len(results) != 1 || results[0].Action != kom.ApplyActionUnchanged {
+		t.Errorf("apply same yaml should be unchanged, got %v", results)
+	}
+
+	// 其他字段管理者修改同一字段，不强制时冲突
+	changed := strings.Replace(yaml, "key: value", "key: other", 1)
+	results = kom.DefaultCluster().Applier().ApplyServerSide(changed, "kom-test-other", false)
+	var conflict *kom.ApplyConflictError
+	if len(results) != 1 || !errors.As(results[0].Error, &conflict) {
+		t.Errorf("apply by other manager should conflict, got %v", results)
+	} else if conflict.Conflicts[0].Manager != "kom-test" {
+		t.Errorf("unexpected conflict %+v", conflict.Conflicts)
+	}
+	results = kom.DefaultCluster().Applier().ApplyServerSide(changed, "kom-test-other", true)
+	if len(results) != 1 || results[0].Action != kom.ApplyActionUpdated {
+		t.Errorf("force apply should update, got %v", results)
+	} else if results[0].Diff != "~data.key" {
+		t.Errorf("unexpected diff summary %s", results[0].Diff)
+	}
+
+	var cm corev1.ConfigMap
+	err := kom.DefaultCluster().Resource(&cm).Namespace("default").Name("ssa-test").Get(&cm).Error
+	if err != nil {
+		t.Errorf("Get error %v", err)
+		return
+	}
+	if cm.Data["key"] != "other" {
+		t.Errorf("expected key=other, got %s", cm.Data["key"])
+	}
+}
+
+func TestApplyServerSideGetError(t *testing.T) {
+	// 获取现有对象失败（非不存在）时，不能报告为创建
+	processor := kom.DefaultCluster().Callback().Get()
+	_ = processor.Before("kom:get").Register("test:forbidden", func(k *kom.Kubectl) error {
+		return apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, k.Statement.Name, errors.New("test"))
+	})
+	defer processor.Remove("test:forbidden")
+
+	yaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ssa-get-error
+  namespace: default
+data:
+  key: value
+`
+	results := kom.DefaultCluster().DryRun().Applier().ApplyServerSide(yaml, "kom-test", false)
+	if len(results) != 1 || results[0].Action != kom.ApplyActionFailed || !apierrors.IsForbidden(results[0].Error) {
+		t.Errorf("get error should fail the apply, got %v", results.Strings())
+	}
+}
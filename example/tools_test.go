@@ -2,8 +2,14 @@ package example
 
 import (
 	"testing"
+	"time"
 
 	"github.com/weibaohui/kom/kom"
+	"github.com/weibaohui/kom/utils"
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 func TestToolCacheClear(t *testing.T) {
@@ -11,3 +17,130 @@ func TestToolCacheClear(t *testing.T) {
 	kom.DefaultCluster().Tools().ClearCache()
 
 }
+func TestToolInvalidateCache(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	kom.DefaultCluster().Tools().InvalidateCache(gvr, "default")
+}
+func TestCacheInvalidateAfterPatch(t *testing.T) {
+	var item v1.Deployment
+	err := kom.DefaultCluster().Resource(&item).Namespace("default").Name("nginx").
+		WithCache(30 * time.Second).Get(&item).Error
+	if err != nil {
+		t.Logf("Get Deployment error %v", err)
+		return
+	}
+	patchData := `{"metadata":{"labels":{"cache-invalidate":"yes"}}}`
+	err = kom.DefaultCluster().Resource(&item).Namespace("default").Name("nginx").
+		Patch(&item, types.MergePatchType, patchData).Error
+	if err != nil {
+		t.Errorf("Patch Deployment error %v", err)
+		return
+	}
+	var cached v1.Deployment
+	err = kom.DefaultCluster().Resource(&cached).Namespace("default").Name("nginx").
+		WithCache(30 * time.Second).Get(&cached).Error
+	if err != nil {
+		t.Errorf("Get Deployment error %v", err)
+		return
+	}
+	if cached.Labels["cache-invalidate"] != "yes" {
+		t.Errorf("cache should be invalidated after patch")
+	}
+}
+func TestCacheInvalidateByDest(t *testing.T) {
+	// 只传入Dest对象写入时，按对象所在的命名空间及名称失效缓存
+	ns, name := "kube-public", "cache-dest-test"
+	var cached []corev1.ConfigMap
+	err := kom.DefaultCluster().Resource(&corev1.ConfigMap{}).Namespace(ns).
+		WithCache(30 * time.Second).List(&cached).Error
+	if err != nil {
+		t.Fatalf("List ConfigMap error %v", err)
+	}
+
+	cm := corev1.ConfigMap{}
+	cm.Namespace, cm.Name = ns, name
+	cm.Data = map[string]string{"key": "value"}
+	err = kom.DefaultCluster().Resource(&corev1.ConfigMap{}).Create(&cm).Error
+	if err != nil {
+		t.Fatalf("Create ConfigMap error %v", err)
+	}
+	defer kom.DefaultCluster().Resource(&corev1.ConfigMap{}).Namespace(ns).Name(name).Delete()
+
+	err = kom.DefaultCluster().Resource(&corev1.ConfigMap{}).Namespace(ns).
+		WithCache(30 * time.Second).List(&cached).Error
+	if err != nil {
+		t.Fatalf("List ConfigMap error %v", err)
+	}
+	found := false
+	for _, item := range cached {
+		found = found || item.Name == name
+	}
+	if !found {
+		t.Errorf("list cache should be invalidated after create")
+	}
+
+	var item corev1.ConfigMap
+	err = kom.DefaultCluster().Resource(&corev1.ConfigMap{}).Namespace(ns).Name(name).
+		WithCache(30 * time.Second).Get(&item).Error
+	if err != nil {
+		t.Fatalf("Get ConfigMap error %v", err)
+	}
+	item.Data["key"] = "changed"
+	err = kom.DefaultCluster().Resource(&corev1.ConfigMap{}).Update(&item).Error
+	if err != nil {
+		t.Fatalf("Update ConfigMap error %v", err)
+	}
+	var got corev1.ConfigMap
+	err = kom.DefaultCluster().Resource(&corev1.ConfigMap{}).Namespace(ns).Name(name).
+		WithCache(30 * time.Second).Get(&got).Error
+	if err != nil {
+		t.Fatalf("Get ConfigMap error %v", err)
+	}
+	if got.Data["key"] != "changed" {
+		t.Errorf("get cache should be invalidated after update, got %s", got.Data["key"])
+	}
+}
+func TestCacheKeyWithSelector(t *testing.T) {
+	var all []corev1.Pod
+	err := kom.DefaultCluster().Resource(&corev1.Pod{}).Namespace("default").
+		WithCache(30 * time.Second).List(&all).Error
+	if err != nil {
+		t.Errorf("List Pod error %v", err)
+		return
+	}
+	var selected []corev1.Pod
+	err = kom.DefaultCluster().Resource(&corev1.Pod{}).Namespace("default").
+		WithLabelSelector("app=random").
+		WithCache(30 * time.Second).List(&selected).Error
+	if err != nil {
+		t.Errorf("List Pod error %v", err)
+		return
+	}
+	for _, p := range selected {
+		if p.Labels["app"] != "random" {
+			t.Errorf("cached list with label selector should not return pod %s", p.Name)
+		}
+	}
+	stats := kom.DefaultCluster().Status().CacheStats()
+	t.Logf("cache stats %s", utils.ToJSON(stats))
+}
+func TestCacheIndexExpire(t *testing.T) {
+	var items []corev1.ConfigMap
+	err := kom.DefaultCluster().Resource(&corev1.ConfigMap{}).Namespace("default").
+		WithLabelSelector("cache-index-expire=yes").
+		WithCache(1 * time.Second).List(&items).Error
+	if err != nil {
+		t.Errorf("List ConfigMap error %v", err)
+		return
+	}
+	before := kom.DefaultCluster().Status().CacheStats().TrackedKeys
+	// 过期的缓存条目由ristretto定期清理，清理后从索引中移除
+	deadline := time.Now().Add(20 * time.Second)
+	for time.Now().Before(deadline) {
+		if kom.DefaultCluster().Status().CacheStats().TrackedKeys < before {
+			return
+		}
+		time.Sleep(time.Second)
+	}
+	t.Errorf("expired cache key should be removed from index, tracked keys %d", before)
+}
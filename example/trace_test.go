@@ -0,0 +1,121 @@
+package example
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	corev1 "k8s.io/api/core/v1"
+)
+
+type spanKey struct{}
+
+type recordedSpan struct {
+	name   string
+	parent string
+	attrs  map[string]string
+	ended  bool
+}
+
+func (s *recordedSpan) SetAttributes(attrs ...kom.Attribute) {
+	for _, attr := range attrs {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+func (s *recordedSpan) End() {
+	s.ended = true
+}
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) StartSpan(ctx context.Context, name string) (context.Context, kom.Span) {
+	span := &recordedSpan{name: name, attrs: map[string]string{}}
+	if parent, ok := ctx.Value(spanKey{}).(*recordedSpan); ok {
+		span.parent = parent.name
+	}
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func TestTracerNestedSpans(t *testing.T) {
+	tracer := &recordingTracer{}
+	kom.SetTracer(tracer)
+	defer kom.SetTracer(nil)
+
+	_, err := kom.DefaultCluster().Resource(&corev1.Pod{}).Namespace("default").Name("random").Ctl().Pod().LinkedIngress()
+	if err != nil {
+		t.Logf("LinkedIngress error %v", err)
+	}
+
+	var root *recordedSpan
+	children := 0
+	requests := 0
+	for _, span := range tracer.spans {
+		if !span.ended {
+			t.Errorf("span %s not ended", span.name)
+		}
+		switch {
+		case span.name == "kom.pod.LinkedIngress":
+			root = span
+		case span.parent == "kom.pod.LinkedIngress":
+			children++
+		case span.name == "HTTP GET":
+			requests++
+		}
+	}
+	if root == nil {
+		t.Errorf("helper span not found")
+		return
+	}
+	if children == 0 || requests == 0 {
+		t.Errorf("expected nested operation and request spans, got %d operations, %d requests", children, requests)
+	}
+	t.Logf("LinkedIngress produced %d operations, %d requests", children, requests)
+}
+
+func TestTracerSpanNotLeaked(t *testing.T) {
+	tracer := &recordingTracer{}
+	kom.SetTracer(tracer)
+	defer kom.SetTracer(nil)
+
+	// 链式调用得到的实例，之后的操作不应挂在已结束的Span下
+	k := kom.DefaultCluster().Resource(&corev1.Pod{}).Namespace("default").Name("random")
+	_, _ = k.Ctl().Pod().LinkedIngress()
+	if _, ok := k.Statement.Context.Value(spanKey{}).(*recordedSpan); ok {
+		t.Errorf("helper span should not leak into the caller's context")
+	}
+}
+
+func TestTracerCallerContextUnchanged(t *testing.T) {
+	kom.SetTracer(&recordingTracer{})
+	defer kom.SetTracer(nil)
+
+	tx := kom.DefaultCluster().Resource(&corev1.Pod{}).Namespace("default")
+	parent := tx.Statement.Context
+	var during, spanCtx context.Context
+	processor := kom.DefaultCluster().Callback().List()
+	_ = processor.Before("kom:list").Register("test:ctx", func(k *kom.Kubectl) error {
+		during = tx.Statement.Context
+		spanCtx = k.Statement.Context
+		return nil
+	})
+	defer processor.Remove("test:ctx")
+
+	var list []corev1.Pod
+	if err := tx.List(&list).Error; err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	// 执行期间调用方的ctx不变，callback使用带有Span的ctx
+	if during != parent {
+		t.Errorf("caller context should not change during execute")
+	}
+	if _, ok := spanCtx.Value(spanKey{}).(*recordedSpan); !ok {
+		t.Errorf("callback should run with the span context")
+	}
+}
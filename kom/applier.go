@@ -1,106 +1,293 @@
 package kom
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/weibaohui/kom/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/yaml"
 )
 
 type applier struct {
 	kubectl *Kubectl
+	prune   *PruneOptions // 应用后清理不再包含在YAML中的资源，通过 WithPrune 设置
 }
 
-func (a *applier) Apply(str string) (result []string) {
-	docs := splitYAML(str)
+// Apply 客户端方式应用，资源不存在时创建，存在时获取后整体更新
+// 按依赖关系排序后执行，如先Namespace、CRD，再ServiceAccount、ConfigMap，最后是工作负载及自定义资源
+// 返回YAML中每个资源的执行结果，顺序与YAML中一致，可通过 Strings() 获取文本形式
+func (a *applier) Apply(str string) ApplyResults {
+	a = a.previewApplier()
+	return a.apply(str, a.withDependencies(a.createOrUpdateCRD))
+}
+
+// ApplyServerSide 服务端应用，以 types.ApplyPatchType 提交，只修改yaml中声明的字段
+// 由其他控制器管理的字段（如HPA管理的replicas）不受影响
+// fieldManager 为空时使用 kom；force 为true时强制接管冲突字段，否则冲突时 ApplyResult.Error 为 *ApplyConflictError
+// 客户端方式（获取后整体更新）请使用 Apply
+func (a *applier) ApplyServerSide(str string, fieldManager string, force bool) ApplyResults {
+	a = a.previewApplier()
+	return a.apply(str, a.withDependencies(func(obj *unstructured.Unstructured) *ApplyResult {
+		return a.serverSideApply(obj, fieldManager, force)
+	}))
+}
+
+// Delete 删除YAML中的资源，按与 Apply 相反的顺序执行，先删除依赖方
+func (a *applier) Delete(str string) ApplyResults {
+	// 记录本次批量删除的对象数量，供钩子检查
+	// 使用复制的实例，不修改调用方的Statement
+	tx := a.kubectl.copyInstance()
+	tx.Statement.BatchSize = len(parseDocuments(str))
+
+	return a.eachDocument(str, true, func(obj *unstructured.Unstructured) *ApplyResult {
+		return a.deleteCRD(tx, obj)
+	})
+}
 
+// eachDocument 解析多文档YAML，按依赖关系排序后对每个资源执行fn，reverse 为true时倒序执行
+// 返回的结果与YAML中的顺序一致
+func (a *applier) eachDocument(str string, reverse bool, fn func(obj *unstructured.Unstructured) *ApplyResult) ApplyResults {
+	docs := parseDocuments(str)
+	results := make(ApplyResults, len(docs))
 	for _, doc := range docs {
-		if strings.TrimSpace(doc) == "" {
-			continue
+		if doc.err != nil {
+			results[doc.index] = failedResult(doc.err)
 		}
-		// 解析 YAML 到 Unstructured 对象
-		var obj unstructured.Unstructured
-		if err := yaml.Unmarshal([]byte(doc), &obj.Object); err != nil {
-			result = append(result, fmt.Sprintf("YAML 解析失败: %v", err))
-			continue
+	}
+	sortDocuments(docs, reverse)
+	for _, doc := range docs {
+		if doc.obj != nil {
+			results[doc.index] = fn(doc.obj)
 		}
-		result = append(result, a.createOrUpdateCRD(&obj))
 	}
-
-	return result
+	return results
 }
-func (a *applier) Delete(str string) (result []string) {
-	docs := splitYAML(str)
 
-	for _, doc := range docs {
+// parseDocuments 解析多文档YAML，跳过空文档
+func parseDocuments(str string) []*applyDocument {
+	var docs []*applyDocument
+	for _, doc := range splitYAML(str) {
 		if strings.TrimSpace(doc) == "" {
 			continue
 		}
+		d := &applyDocument{index: len(docs)}
 		// 解析 YAML 到 Unstructured 对象
 		var obj unstructured.Unstructured
 		if err := yaml.Unmarshal([]byte(doc), &obj.Object); err != nil {
-			result = append(result, fmt.Sprintf("YAML 解析失败: %v", err))
-			continue
+			d.err = fmt.Errorf("YAML 解析失败: %v", err)
+		} else {
+			d.obj = &obj
 		}
-		result = append(result, a.deleteCRD(&obj))
+		docs = append(docs, d)
 	}
-
-	return result
+	return docs
 }
-func (a *applier) createOrUpdateCRD(obj *unstructured.Unstructured) string {
+
+func (a *applier) createOrUpdateCRD(obj *unstructured.Unstructured) *ApplyResult {
 	// 提取 Group, Version, Kind
 	gvk := obj.GroupVersionKind()
 	if gvk.Kind == "" || gvk.Version == "" {
-		return fmt.Sprintf("YAML 缺少必要的 Group, Version 或 Kind")
+		return failedResult(fmt.Errorf("YAML 缺少必要的 Group, Version 或 Kind"))
 	}
 
 	_, namespaced := a.kubectl.Tools().ParseGVK2GVR([]schema.GroupVersionKind{gvk})
 
 	ns := obj.GetNamespace()
 	name := obj.GetName()
-	kind := obj.GetKind()
 
 	if ns == "" && namespaced {
 		ns = metav1.NamespaceDefault // 默认命名空间
 		obj.SetNamespace(ns)
 	}
+	result := newApplyResult(obj)
+	result.DryRun = a.kubectl.Statement.DryRun
+
 	var cr *unstructured.Unstructured
 	err := a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Namespace(ns).Name(name).Get(&cr).Error
+	if err != nil && !apierrors.IsNotFound(err) {
+		// 无权限、超时等错误，不能当作不存在而去创建
+		return result.fail("get", err)
+	}
 
 	if err == nil && cr != nil && cr.GetName() != "" {
 		// 已经存在资源，那么就更新
 		obj.SetResourceVersion(cr.GetResourceVersion())
 		err = a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Name(name).Namespace(ns).Update(&obj).Error
 		if err != nil {
-			return fmt.Sprintf("update %s/%s,%s %s/%s error:%v", gvk.Group, gvk.Version, gvk.Kind, ns, name, err)
+			return result.fail("update", err)
 		}
-		return fmt.Sprintf("%s/%s updated", kind, name)
+		result.Object = obj
+		result.live = cr
+		result.Diff = changeSummary(cr, obj)
+		result.Action = ApplyActionUpdated
+		if result.Diff == "" {
+			result.Action = ApplyActionUnchanged
+		}
+		return result
 	} else {
 		// 不存在，那么就创建
 		err = a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Name(name).Namespace(ns).Create(&obj).Error
 		if err != nil {
-			return fmt.Sprintf("create %s/%s,%s %s/%s error:%v", gvk.Group, gvk.Version, gvk.Kind, ns, name, err)
+			return result.fail("create", err)
 		}
-		return fmt.Sprintf("%s/%s created", kind, name)
+		result.Object = obj
+		result.Action = ApplyActionCreated
+		return result
 	}
 }
-func (a *applier) deleteCRD(obj *unstructured.Unstructured) string {
-	// 提取 Group, Version, Kind
+
+// 服务端应用默认的字段管理者名称
+const defaultFieldManager = "kom"
+
+func (a *applier) serverSideApply(obj *unstructured.Unstructured, fieldManager string, force bool) *ApplyResult {
 	gvk := obj.GroupVersionKind()
 	if gvk.Kind == "" || gvk.Version == "" {
-		return fmt.Sprintf("YAML 缺少必要的 Group, Version 或 Kind")
+		return failedResult(fmt.Errorf("YAML 缺少必要的 Group, Version 或 Kind"))
+	}
+	if fieldManager == "" {
+		fieldManager = defaultFieldManager
 	}
+
+	_, namespaced := a.kubectl.Tools().ParseGVK2GVR([]schema.GroupVersionKind{gvk})
+
 	ns := obj.GetNamespace()
 	name := obj.GetName()
-	err := a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Namespace(ns).Name(name).Delete().Error
+
+	if ns == "" && namespaced {
+		ns = metav1.NamespaceDefault // 默认命名空间
+		obj.SetNamespace(ns)
+	}
+	result := newApplyResult(obj)
+	result.DryRun = a.kubectl.Statement.DryRun
+
+	// 服务端应用不允许提交managedFields
+	obj.SetManagedFields(nil)
+	data, err := json.Marshal(obj.Object)
 	if err != nil {
-		return fmt.Sprintf("delete %s/%s,%s %s/%s error:%v", gvk.Group, gvk.Version, gvk.Kind, ns, name, err)
+		return result.fail("apply", err)
+	}
+
+	// 先获取现有对象，用于区分创建、更新以及未变化
+	// 只有不存在时才视为创建，无权限、超时等错误直接返回，避免误报为创建
+	var current *unstructured.Unstructured
+	err = a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Namespace(ns).Name(name).Get(&current).Error
+	if err != nil && !apierrors.IsNotFound(err) {
+		return result.fail("get", err)
 	}
-	return fmt.Sprintf("%s/%s deleted", gvk.Kind, name)
+
+	tx := a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Namespace(ns).Name(name).WithFieldManager(fieldManager)
+	if force {
+		tx = tx.ForceConflicts()
+	}
+	var res *unstructured.Unstructured
+	err = tx.Patch(&res, types.ApplyPatchType, string(data)).Error
+	if err != nil {
+		if conflicts := ApplyConflicts(err); len(conflicts) > 0 {
+			err = &ApplyConflictError{Err: err, Conflicts: conflicts}
+		}
+		return result.fail("apply", err)
+	}
+	result.Object = res
+	if current == nil || current.GetName() == "" {
+		result.Action = ApplyActionCreated
+		return result
+	}
+	result.live = current
+	result.Diff = changeSummary(current, res)
+	result.Action = ApplyActionUpdated
+	if result.Diff == "" {
+		result.Action = ApplyActionUnchanged
+	}
+	return result
+}
+
+// FieldConflict 服务端应用时与其他字段管理者冲突的字段
+type FieldConflict struct {
+	Manager string `json:"manager"` // 当前管理该字段的字段管理者
+	Field   string `json:"field"`   // 冲突的字段，如 .spec.replicas
+	Message string `json:"message"` // API Server 返回的原始信息
+}
+
+// ApplyConflictError 服务端应用时的字段冲突
+type ApplyConflictError struct {
+	Err       error
+	Conflicts []FieldConflict
+}
+
+func (e *ApplyConflictError) Error() string {
+	var fields []string
+	for _, c := range e.Conflicts {
+		fields = append(fields, fmt.Sprintf("%s(managed by %s)", c.Field, c.Manager))
+	}
+	return fmt.Sprintf("apply conflicts with other field managers: %s", strings.Join(fields, ", "))
+}
+
+func (e *ApplyConflictError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyConflicts 从服务端应用返回的冲突错误中解析冲突字段，非冲突错误返回nil
+func ApplyConflicts(err error) []FieldConflict {
+	if err == nil || !apierrors.IsConflict(err) {
+		return nil
+	}
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return nil
+	}
+	details := status.Status().Details
+	if details == nil {
+		return nil
+	}
+	var conflicts []FieldConflict
+	for _, cause := range details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		conflicts = append(conflicts, FieldConflict{
+			Manager: conflictManager(cause.Message),
+			Field:   cause.Field,
+			Message: cause.Message,
+		})
+	}
+	return conflicts
+}
+
+// conflictManager 从冲突信息中提取字段管理者名称
+// 如 conflict with "kube-controller-manager" using apps/v1
+func conflictManager(message string) string {
+	start := strings.Index(message, `"`)
+	if start < 0 {
+		return ""
+	}
+	end := strings.Index(message[start+1:], `"`)
+	if end < 0 {
+		return ""
+	}
+	return message[start+1 : start+1+end]
+}
+
+func (a *applier) deleteCRD(kubectl *Kubectl, obj *unstructured.Unstructured) *ApplyResult {
+	// 提取 Group, Version, Kind
+	gvk := obj.GroupVersionKind()
+	if gvk.Kind == "" || gvk.Version == "" {
+		return failedResult(fmt.Errorf("YAML 缺少必要的 Group, Version 或 Kind"))
+	}
+	result := newApplyResult(obj)
+	result.DryRun = kubectl.Statement.DryRun
+	err := kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Namespace(result.Namespace).Name(result.Name).Delete().Error
+	if err != nil {
+		return result.fail("delete", err)
+	}
+	result.Action = ApplyActionDeleted
+	return result
 }
 
 // splitYAML 按 "---" 分割多文档 YAML
@@ -0,0 +1,152 @@
+package kom
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/weibaohui/kom/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// 对比时忽略的字段，在 diffIgnoredFields 基础上忽略创建时由服务端生成的字段
+var diffNoisyFields = append([][]string{
+	{"metadata", "uid"},
+	{"metadata", "creationTimestamp"},
+}, diffIgnoredFields...)
+
+// DiffResult YAML中一个资源应用前后的差异
+type DiffResult struct {
+	GVK       schema.GroupVersionKind    `json:"gvk"`
+	Namespace string                     `json:"namespace,omitempty"`
+	Name      string                     `json:"name,omitempty"`
+	Action    ApplyAction                `json:"action"`           // 应用后的结果，created、updated、unchanged、failed
+	Error     error                      `json:"-"`                // 失败时的错误，Action 为 failed
+	Live      *unstructured.Unstructured `json:"live,omitempty"`   // 集群中的当前对象，不存在时为nil
+	Merged    *unstructured.Unstructured `json:"merged,omitempty"` // 服务端试运行应用后的对象
+	Diff      string                     `json:"diff,omitempty"`   // unified diff 格式的差异，YAML形式对比，没有变化时为空
+	Patch     []utils.JSONPatchOperation `json:"patch,omitempty"`  // 从当前对象到应用后对象的JSON Patch操作
+}
+
+// DiffResults YAML中每个资源的差异，顺序与YAML中一致
+type DiffResults []*DiffResult
+
+// String 合并所有资源的差异
+func (rs DiffResults) String() string {
+	var sb strings.Builder
+	for _, r := range rs {
+		sb.WriteString(r.Diff)
+	}
+	return sb.String()
+}
+
+// Err 合并所有失败资源的错误，全部成功时为nil
+func (rs DiffResults) Err() error {
+	var errs []error
+	for _, r := range rs {
+		if r.Error != nil {
+			errs = append(errs, fmt.Errorf("diff %s/%s,%s %s/%s error:%v", r.GVK.Group, r.GVK.Version, r.GVK.Kind, r.Namespace, r.Name, r.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Diff 预览 Apply 将产生的变更，不修改集群
+// 逐个资源获取当前对象，以服务端试运行方式创建或更新，对比两者的差异
+// 忽略 managedFields、resourceVersion、generation、status 等每次变更都会变化的字段
+func (a *applier) Diff(str string) DiffResults {
+	return a.diff(str, func(dry *applier, obj *unstructured.Unstructured) *ApplyResult {
+		return dry.createOrUpdateCRD(obj)
+	})
+}
+
+// DiffServerSide 预览 ApplyServerSide 将产生的变更，不修改集群，参数与 ApplyServerSide 一致
+func (a *applier) DiffServerSide(str string, fieldManager string, force bool) DiffResults {
+	return a.diff(str, func(dry *applier, obj *unstructured.Unstructured) *ApplyResult {
+		return dry.serverSideApply(obj, fieldManager, force)
+	})
+}
+
+// diff 以试运行方式执行fn，逐个资源对比，结果顺序与YAML中一致
+func (a *applier) diff(str string, fn func(dry *applier, obj *unstructured.Unstructured) *ApplyResult) DiffResults {
+	// 使用复制的实例，不修改调用方的Statement
+	tx := a.kubectl.copyInstance()
+	tx.Statement.DryRun = true
+	dry := &applier{kubectl: tx}
+	var results DiffResults
+	for _, doc := range parseDocuments(str) {
+		if doc.err != nil {
+			results = append(results, &DiffResult{Action: ApplyActionFailed, Error: doc.err})
+			continue
+		}
+		results = append(results, a.diffDocument(fn(dry, doc.obj.DeepCopy())))
+	}
+	return results
+}
+
+// diffDocument 试运行前获取到的当前对象与试运行的结果对比
+func (a *applier) diffDocument(applied *ApplyResult) *DiffResult {
+	result := &DiffResult{
+		GVK:       applied.GVK,
+		Namespace: applied.Namespace,
+		Name:      applied.Name,
+		Action:    applied.Action,
+		Merged:    applied.Object,
+		Error:     applied.Error,
+	}
+	if result.Error != nil {
+		return result
+	}
+
+	// 使用试运行前获取到的现有对象，不再重新获取，避免两次读取之间对象发生变化；创建时为nil
+	result.Live = applied.live
+
+	before := stripNoisyFields(result.Live)
+	after := stripNoisyFields(result.Merged)
+	result.Patch = utils.CreateJSONPatch(before, after)
+
+	fromYAML, err := toDiffYAML(before)
+	if err != nil {
+		result.Action = ApplyActionFailed
+		result.Error = err
+		return result
+	}
+	toYAML, err := toDiffYAML(after)
+	if err != nil {
+		result.Action = ApplyActionFailed
+		result.Error = err
+		return result
+	}
+	path := fmt.Sprintf("%s/%s", result.GVK.Kind, result.Name)
+	if result.Namespace != "" {
+		path = fmt.Sprintf("%s/%s/%s", result.GVK.Kind, result.Namespace, result.Name)
+	}
+	result.Diff = utils.UnifiedDiff(fromYAML, toYAML, "live/"+path, "merged/"+path, 3)
+	return result
+}
+
+// stripNoisyFields 复制对象并删除对比时忽略的字段，对象为nil时返回空
+func stripNoisyFields(obj *unstructured.Unstructured) map[string]interface{} {
+	if obj == nil {
+		return map[string]interface{}{}
+	}
+	m := obj.DeepCopy().Object
+	for _, fields := range diffNoisyFields {
+		unstructured.RemoveNestedField(m, fields...)
+	}
+	return m
+}
+
+// toDiffYAML 转换为YAML用于按行对比，字段按名称排序，空对象为空字符串
+func toDiffYAML(m map[string]interface{}) (string, error) {
+	if len(m) == 0 {
+		return "", nil
+	}
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
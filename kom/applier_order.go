@@ -0,0 +1,158 @@
+package kom
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// 等待CRD就绪的超时时间
+	crdEstablishTimeout = 60 * time.Second
+	// 检查CRD是否就绪的间隔
+	crdEstablishInterval = 500 * time.Millisecond
+)
+
+// applyKindOrder 按依赖关系排列的资源类型，靠前的先创建、后删除
+// 未列出的类型（如自定义资源）排在最后
+var applyKindOrder = []string{
+	"Namespace",
+	"CustomResourceDefinition",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PriorityClass",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Secret",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"IngressClass",
+	"Ingress",
+	"APIService",
+	"MutatingWebhookConfiguration",
+	"ValidatingWebhookConfiguration",
+}
+
+var applyKindPriority = func() map[string]int {
+	m := make(map[string]int, len(applyKindOrder))
+	for i, kind := range applyKindOrder {
+		m[kind] = i
+	}
+	return m
+}()
+
+// kindPriority 资源类型的排序优先级，越小越先创建
+func kindPriority(kind string) int {
+	if p, ok := applyKindPriority[kind]; ok {
+		return p
+	}
+	return len(applyKindOrder)
+}
+
+// applyDocument YAML中的单个资源
+type applyDocument struct {
+	index int // 在YAML中的位置，执行结果按此顺序返回
+	obj   *unstructured.Unstructured
+	err   error // 解析失败的原因
+}
+
+// sortDocuments 按依赖关系排序，同类资源保持YAML中的顺序
+// reverse 为true时倒序，删除时先删除依赖方
+func sortDocuments(docs []*applyDocument, reverse bool) {
+	priority := func(d *applyDocument) int {
+		if d.obj == nil {
+			return -1
+		}
+		p := kindPriority(d.obj.GetKind())
+		if reverse {
+			return -p
+		}
+		return p
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		return priority(docs[i]) < priority(docs[j])
+	})
+}
+
+// withDependencies 应用CRD后等待其就绪，并在应用其他资源前刷新API资源，
+// 使同一YAML中的自定义资源能够解析到新的CRD
+func (a *applier) withDependencies(fn func(obj *unstructured.Unstructured) *ApplyResult) func(obj *unstructured.Unstructured) *ApplyResult {
+	refreshPending := false
+	return func(obj *unstructured.Unstructured) *ApplyResult {
+		isCRD := obj.GetKind() == "CustomResourceDefinition"
+		if refreshPending && !isCRD {
+			refreshPending = false
+			if err := a.kubectl.refreshDiscovery(); err != nil {
+				klog.V(2).Infof("refresh discovery after applying CRDs error: %v", err)
+			}
+		}
+		result := fn(obj)
+		if !isCRD || result.Error != nil || result.DryRun || result.Action == ApplyActionUnchanged {
+			return result
+		}
+		if err := a.waitForCRDEstablished(result.Name); err != nil {
+			return result.fail("wait", err)
+		}
+		refreshPending = true
+		return result
+	}
+}
+
+// waitForCRDEstablished 等待CRD的 Established 状态为True
+func (a *applier) waitForCRDEstablished(name string) error {
+	ctx := a.kubectl.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	err := wait.PollUntilContextTimeout(ctx, crdEstablishInterval, crdEstablishTimeout, true, func(ctx context.Context) (bool, error) {
+		crd, err := a.kubectl.DynamicClient().Resource(crdGVR).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			// 刚创建时可能暂时获取不到，继续等待
+			klog.V(4).Infof("get crd %s error: %v", name, err)
+			return false, nil
+		}
+		return crdEstablished(crd), nil
+	})
+	if err != nil {
+		return fmt.Errorf("wait for crd %s established error: %v", name, err)
+	}
+	return nil
+}
+
+// crdEstablished CRD是否已就绪
+func crdEstablished(crd *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["type"] == "Established" && condition["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
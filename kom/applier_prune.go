@@ -0,0 +1,163 @@
+package kom
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+)
+
+// ApplySetLabel 标记资源所属应用的标签，开启清理时写入每个资源
+const ApplySetLabel = "kom.io/apply-set"
+
+// DefaultPruneKinds 默认允许清理的资源类型，不包括Namespace、PersistentVolume等影响范围较大的类型
+var DefaultPruneKinds = []schema.GroupVersionKind{
+	{Version: "v1", Kind: "ConfigMap"},
+	{Version: "v1", Kind: "Secret"},
+	{Version: "v1", Kind: "Service"},
+	{Version: "v1", Kind: "ServiceAccount"},
+	{Version: "v1", Kind: "PersistentVolumeClaim"},
+	{Group: "apps", Version: "v1", Kind: "Deployment"},
+	{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+	{Group: "apps", Version: "v1", Kind: "DaemonSet"},
+	{Group: "batch", Version: "v1", Kind: "Job"},
+	{Group: "batch", Version: "v1", Kind: "CronJob"},
+	{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "Role"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "RoleBinding"},
+	{Group: "autoscaling", Version: "v2", Kind: "HorizontalPodAutoscaler"},
+}
+
+// PruneOptions 应用时清理不再包含在YAML中的资源
+type PruneOptions struct {
+	ApplySet string                    // 应用标识，写入 ApplySetLabel 标签，只清理带有相同标识的资源，必填
+	Kinds    []schema.GroupVersionKind // 允许清理的资源类型，为空时使用 DefaultPruneKinds
+	Preview  bool                      // 预览，整个应用及清理过程均以服务端试运行方式执行，不修改集群
+}
+
+// WithPrune 开启清理，之后的 Apply、ApplyServerSide 会为每个资源写入应用标识，
+// 并删除带有该标识、但已不在YAML中的资源
+// 有资源应用失败时不清理，避免误删
+func (a *applier) WithPrune(opts PruneOptions) *applier {
+	return &applier{
+		kubectl: a.kubectl,
+		prune:   &opts,
+	}
+}
+
+// previewApplier 开启清理预览时，返回以服务端试运行方式执行的applier，应用及清理均不修改集群
+// 使用复制的实例，不修改调用方的Statement
+func (a *applier) previewApplier() *applier {
+	if a.prune == nil || !a.prune.Preview {
+		return a
+	}
+	tx := a.kubectl.copyInstance()
+	tx.Statement.DryRun = true
+	return &applier{
+		kubectl: tx,
+		prune:   a.prune,
+	}
+}
+
+// apply 应用YAML，开启清理时写入应用标识并在全部成功后清理
+func (a *applier) apply(str string, fn func(obj *unstructured.Unstructured) *ApplyResult) ApplyResults {
+	if a.prune == nil {
+		return a.eachDocument(str, false, fn)
+	}
+	opts := a.prune
+	if errs := validation.IsValidLabelValue(opts.ApplySet); opts.ApplySet == "" || len(errs) > 0 {
+		err := fmt.Errorf("invalid apply set %q: %s", opts.ApplySet, strings.Join(errs, "; "))
+		var results ApplyResults
+		for _, doc := range parseDocuments(str) {
+			if doc.obj != nil {
+				results = append(results, newApplyResult(doc.obj).fail("apply", err))
+			} else {
+				results = append(results, failedResult(doc.err))
+			}
+		}
+		return results
+	}
+
+	results := a.eachDocument(str, false, func(obj *unstructured.Unstructured) *ApplyResult {
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[ApplySetLabel] = opts.ApplySet
+		obj.SetLabels(labels)
+		return fn(obj)
+	})
+	if err := results.Err(); err != nil {
+		klog.V(2).Infof("skip prune of apply set %s, apply error: %v", opts.ApplySet, err)
+		return results
+	}
+	return append(results, a.pruneObjects(results)...)
+}
+
+// pruneObjects 删除带有应用标识、但不在本次应用结果中的资源
+func (a *applier) pruneObjects(applied ApplyResults) ApplyResults {
+	opts := a.prune
+	kinds := opts.Kinds
+	if len(kinds) == 0 {
+		kinds = DefaultPruneKinds
+	}
+	// 本次应用的资源，集群级别的资源忽略YAML中填写的命名空间，与列表结果一致
+	keep := make(map[string]bool, len(applied))
+	for _, r := range applied {
+		ns := r.Namespace
+		if _, namespaced := a.kubectl.Tools().ParseGVK2GVR([]schema.GroupVersionKind{r.GVK}); !namespaced {
+			ns = ""
+		}
+		keep[pruneKey(r.GVK.GroupKind(), ns, r.Name)] = true
+	}
+
+	var docs []*applyDocument
+	var results ApplyResults
+	for _, gvk := range kinds {
+		var list []unstructured.Unstructured
+		err := a.kubectl.newInstance().CRD(gvk.Group, gvk.Version, gvk.Kind).
+			AllNamespace().
+			WithLabelSelector(fmt.Sprintf("%s=%s", ApplySetLabel, opts.ApplySet)).
+			List(&list).Error
+		if err != nil {
+			// 集群中可能不存在该类型，如旧版本集群没有 autoscaling/v2
+			klog.V(2).Infof("list %s of apply set %s error: %v", gvk.String(), opts.ApplySet, err)
+			continue
+		}
+		for i := range list {
+			item := &list[i]
+			if keep[pruneKey(gvk.GroupKind(), item.GetNamespace(), item.GetName())] {
+				continue
+			}
+			item.SetGroupVersionKind(gvk)
+			docs = append(docs, &applyDocument{index: len(docs), obj: item})
+		}
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+
+	// 按依赖关系倒序删除，与 Delete 一致
+	sortDocuments(docs, true)
+	// 使用复制的实例，不修改调用方的Statement；预览时已为试运行
+	tx := a.kubectl.copyInstance()
+	tx.Statement.BatchSize = len(docs)
+	for _, doc := range docs {
+		result := a.deleteCRD(tx, doc.obj)
+		if result.Error == nil {
+			result.Action = ApplyActionPruned
+		} else {
+			result.operation = "prune"
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// pruneKey 资源的唯一标识，不区分版本
+func pruneKey(gk schema.GroupKind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", gk.String(), namespace, name)
+}
@@ -0,0 +1,155 @@
+package kom
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/weibaohui/kom/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ApplyAction YAML中单个资源的执行动作
+type ApplyAction string
+
+const (
+	ApplyActionCreated   ApplyAction = "created"
+	ApplyActionUpdated   ApplyAction = "updated"
+	ApplyActionUnchanged ApplyAction = "unchanged" // 更新前后没有变化
+	ApplyActionDeleted   ApplyAction = "deleted"
+	ApplyActionPruned    ApplyAction = "pruned" // 已不在YAML中，通过 WithPrune 清理
+	ApplyActionFailed    ApplyAction = "failed"
+)
+
+// ApplyResult YAML中单个资源的执行结果
+type ApplyResult struct {
+	GVK       schema.GroupVersionKind    `json:"gvk"`
+	Namespace string                     `json:"namespace,omitempty"`
+	Name      string                     `json:"name,omitempty"`
+	Action    ApplyAction                `json:"action"`
+	Error     error                      `json:"-"`                // 失败时的错误，Action 为 failed
+	Object    *unstructured.Unstructured `json:"object,omitempty"` // 服务端返回的对象，删除或失败时为nil
+	Diff      string                     `json:"diff,omitempty"`   // 更新时变更的字段摘要，如 ~spec.replicas +metadata.labels.app
+	DryRun    bool                       `json:"dryRun,omitempty"` // 是否为试运行
+
+	operation string                     // 失败时执行的操作，get、create、update、apply、delete、prune，用于输出
+	live      *unstructured.Unstructured // 应用前获取到的现有对象，不存在时为nil，供 Diff 对比
+}
+
+// String 文本形式，与之前返回的字符串一致，如 Deployment/nginx created
+func (r *ApplyResult) String() string {
+	if r.Error != nil {
+		if r.operation == "" {
+			return r.Error.Error()
+		}
+		return fmt.Sprintf("%s %s/%s,%s %s/%s error:%v", r.operation, r.GVK.Group, r.GVK.Version, r.GVK.Kind, r.Namespace, r.Name, r.Error)
+	}
+	s := fmt.Sprintf("%s/%s %s", r.GVK.Kind, r.Name, r.Action)
+	// 试运行时追加标识，与kubectl一致
+	if r.DryRun {
+		s += " (server dry run)"
+	}
+	return s
+}
+
+// ApplyResults YAML中每个资源的执行结果，顺序与YAML中一致
+type ApplyResults []*ApplyResult
+
+// Strings 每个资源执行结果的文本形式
+func (rs ApplyResults) Strings() []string {
+	result := make([]string, 0, len(rs))
+	for _, r := range rs {
+		result = append(result, r.String())
+	}
+	return result
+}
+
+// Failed 执行失败的资源
+func (rs ApplyResults) Failed() ApplyResults {
+	var result ApplyResults
+	for _, r := range rs {
+		if r.Error != nil {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// Err 合并所有失败资源的错误，全部成功时为nil
+func (rs ApplyResults) Err() error {
+	var errs []error
+	for _, r := range rs.Failed() {
+		errs = append(errs, errors.New(r.String()))
+	}
+	return errors.Join(errs...)
+}
+
+// newApplyResult 根据YAML中的对象创建执行结果
+func newApplyResult(obj *unstructured.Unstructured) *ApplyResult {
+	return &ApplyResult{
+		GVK:       obj.GroupVersionKind(),
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	}
+}
+
+// fail 标记为失败
+func (r *ApplyResult) fail(operation string, err error) *ApplyResult {
+	r.Action = ApplyActionFailed
+	r.operation = operation
+	r.Error = err
+	return r
+}
+
+// failedResult YAML解析失败等无法识别资源时的结果
+func failedResult(err error) *ApplyResult {
+	return &ApplyResult{Action: ApplyActionFailed, Error: err}
+}
+
+// 计算变更摘要时忽略的字段，每次更新都会变化或由服务端维护
+var diffIgnoredFields = [][]string{
+	{"metadata", "resourceVersion"},
+	{"metadata", "generation"},
+	{"metadata", "managedFields"},
+	{"status"},
+}
+
+// changeSummary 对比更新前后的对象，返回变更字段的摘要
+// +为新增字段，-为删除字段，~为修改字段，数组整体比较
+// 由 utils.CreateJSONPatch 的结果转换而来，与 Diff 返回的 Patch 保持一致
+func changeSummary(before, after *unstructured.Unstructured) string {
+	if before == nil || after == nil {
+		return ""
+	}
+	b := before.DeepCopy().Object
+	a := after.DeepCopy().Object
+	for _, fields := range diffIgnoredFields {
+		unstructured.RemoveNestedField(b, fields...)
+		unstructured.RemoveNestedField(a, fields...)
+	}
+	var changes []string
+	for _, op := range utils.CreateJSONPatch(b, a) {
+		changes = append(changes, changeMarks[op.Op]+pointerToPath(op.Path))
+	}
+	sort.Strings(changes)
+	return strings.Join(changes, " ")
+}
+
+// changeMarks JSON Patch 操作在变更摘要中的标记
+var changeMarks = map[string]string{
+	"add":     "+",
+	"remove":  "-",
+	"replace": "~",
+}
+
+// pointerToPath 将JSON Pointer转换为以.分隔的字段路径，如 /spec/replicas 转换为 spec.replicas
+func pointerToPath(pointer string) string {
+	parts := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, part := range parts {
+		part = strings.ReplaceAll(part, "~1", "/")
+		parts[i] = strings.ReplaceAll(part, "~0", "~")
+	}
+	return strings.Join(parts, ".")
+}
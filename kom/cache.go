@@ -0,0 +1,254 @@
+package kom
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/dgraph-io/ristretto/v2"
+	"github.com/dgraph-io/ristretto/v2/z"
+	"github.com/weibaohui/kom/utils"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// cacheIndex 记录查询缓存key与资源的对应关系
+// ristretto 不支持按前缀删除，变更资源后需要依据该索引找到受影响的缓存key
+type cacheIndex struct {
+	mu      sync.Mutex
+	entries map[schema.GroupVersionResource]map[string]cacheRef
+	hashes  map[uint64]cacheHash // 按ristretto的key hash查找，淘汰或过期时据此移除
+}
+
+// cacheRef 缓存key对应的资源范围
+// namespace 为空表示集群级资源或全部命名空间，name 为空表示列表查询
+type cacheRef struct {
+	namespace string
+	name      string
+}
+
+// cacheHash ristretto淘汰回调只提供key的hash，记录hash对应的缓存key
+type cacheHash struct {
+	conflict uint64
+	gvr      schema.GroupVersionResource
+	key      string
+}
+
+func newCacheIndex() *cacheIndex {
+	return &cacheIndex{
+		entries: make(map[schema.GroupVersionResource]map[string]cacheRef),
+		hashes:  make(map[uint64]cacheHash),
+	}
+}
+
+// track 登记缓存key
+func (ci *cacheIndex) track(gvr schema.GroupVersionResource, ns string, name string, key string) {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	keys, ok := ci.entries[gvr]
+	if !ok {
+		keys = make(map[string]cacheRef)
+		ci.entries[gvr] = keys
+	}
+	keys[key] = cacheRef{namespace: ns, name: name}
+	hash, conflict := z.KeyToHash(key)
+	ci.hashes[hash] = cacheHash{conflict: conflict, gvr: gvr, key: key}
+}
+
+// evict 缓存条目被ristretto淘汰、过期或拒绝写入时，从索引中移除
+func (ci *cacheIndex) evict(hash uint64, conflict uint64) {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	h, ok := ci.hashes[hash]
+	if !ok || (conflict != 0 && h.conflict != conflict) {
+		return
+	}
+	delete(ci.hashes, hash)
+	if keys, ok := ci.entries[h.gvr]; ok {
+		delete(keys, h.key)
+		if len(keys) == 0 {
+			delete(ci.entries, h.gvr)
+		}
+	}
+}
+
+// forget 从hash索引中移除key，调用方需持有锁
+func (ci *cacheIndex) forget(key string) {
+	hash, _ := z.KeyToHash(key)
+	delete(ci.hashes, hash)
+}
+
+// match 返回受影响的缓存key，并从索引中移除
+// ns 为空时不限制命名空间，否则返回该命名空间以及跨命名空间（集群级）的key
+// name 为空时不限制名称，否则只返回列表查询以及同名资源的key
+func (ci *cacheIndex) match(gvr schema.GroupVersionResource, ns string, name string) []string {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	keys, ok := ci.entries[gvr]
+	if !ok {
+		return nil
+	}
+	var result []string
+	for key, ref := range keys {
+		if ns != "" && ref.namespace != "" && ref.namespace != ns {
+			continue
+		}
+		if name != "" && ref.name != "" && ref.name != name {
+			continue
+		}
+		result = append(result, key)
+		delete(keys, key)
+		ci.forget(key)
+	}
+	if len(keys) == 0 {
+		delete(ci.entries, gvr)
+	}
+	return result
+}
+
+// reset 清空索引，返回索引中登记的全部key
+func (ci *cacheIndex) reset() []string {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	var result []string
+	for _, keys := range ci.entries {
+		for key := range keys {
+			result = append(result, key)
+		}
+	}
+	ci.entries = make(map[schema.GroupVersionResource]map[string]cacheRef)
+	ci.hashes = make(map[uint64]cacheHash)
+	return result
+}
+
+// count 索引中登记的key数量
+func (ci *cacheIndex) count() int {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	total := 0
+	for _, keys := range ci.entries {
+		total += len(keys)
+	}
+	return total
+}
+
+// CacheStats 集群查询缓存统计，数据来自ristretto metrics
+type CacheStats struct {
+	Hits        uint64  `json:"hits"`        // 命中次数
+	Misses      uint64  `json:"misses"`      // 未命中次数
+	HitRatio    float64 `json:"hitRatio"`    // 命中率
+	KeysAdded   uint64  `json:"keysAdded"`   // 新增key数量
+	KeysUpdated uint64  `json:"keysUpdated"` // 更新key数量
+	KeysEvicted uint64  `json:"keysEvicted"` // 淘汰key数量
+	SetsDropped uint64  `json:"setsDropped"` // 因缓冲区满被丢弃的写入次数
+	Bytes       int64   `json:"bytes"`       // 当前占用，等于新增cost减去淘汰cost，不含主动失效的部分
+	MaxBytes    int64   `json:"maxBytes"`    // 最大容量
+	TrackedKeys int     `json:"trackedKeys"` // 可按资源失效的key数量
+	Shared      bool    `json:"shared"`      // 是否为全局共享缓存，共享时命中、占用等数据为所有集群的合计
+	Disabled    bool    `json:"disabled"`    // 是否禁用了缓存
+}
+
+const (
+	defaultCacheNumCounters = 1e6       // 默认访问频率计数器数量
+	defaultCacheMaxCost     = 256 << 20 // 默认缓存最大占用 256MB
+	sharedCacheNumCounters  = 1e7       // 全局共享缓存访问频率计数器数量
+)
+
+// cacheListeners 使用同一缓存的集群索引，缓存条目被淘汰时通知每个索引
+// 共享缓存对应多个集群，独立缓存只有一个
+type cacheListeners struct {
+	mu      sync.RWMutex
+	indexes map[*cacheIndex]struct{}
+}
+
+func newCacheListeners() *cacheListeners {
+	return &cacheListeners{
+		indexes: make(map[*cacheIndex]struct{}),
+	}
+}
+
+func (l *cacheListeners) add(index *cacheIndex) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.indexes[index] = struct{}{}
+}
+
+func (l *cacheListeners) remove(index *cacheIndex) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.indexes, index)
+}
+
+// onEvict ristretto淘汰、过期以及拒绝写入的回调
+func (l *cacheListeners) onEvict(item *ristretto.Item[any]) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for index := range l.indexes {
+		index.evict(item.Key, item.Conflict)
+	}
+}
+
+// newCache 创建查询缓存，缓存条目的cost由 utils.EstimateCost 按条目数估算
+// 条目被淘汰、过期或拒绝写入时，从listeners中的索引移除
+func newCache(numCounters int64, maxCost int64, listeners *cacheListeners) (*ristretto.Cache[string, any], error) {
+	return ristretto.NewCache(&ristretto.Config[string, any]{
+		NumCounters: numCounters,
+		MaxCost:     maxCost,
+		BufferItems: 64,   // number of keys per Get buffer.
+		Metrics:     true, // 开启统计，供 Status().CacheStats() 使用
+		Cost: func(value any) int64 {
+			return utils.EstimateCost(value)
+		},
+		OnEvict:  listeners.onEvict,
+		OnReject: listeners.onEvict,
+	})
+}
+
+// newClusterCache 按注册参数为集群创建查询缓存，并将index注册到缓存的淘汰回调
+// 设置了全局共享缓存且未要求独立缓存时，返回共享缓存
+func (c *ClusterInstances) newClusterCache(opts CacheOptions, index *cacheIndex) (cache *ristretto.Cache[string, any], listeners *cacheListeners, shared bool, err error) {
+	if opts.Disabled {
+		return nil, nil, false, nil
+	}
+	c.mu.RLock()
+	sharedCache := c.sharedCache
+	sharedListeners := c.sharedCacheListeners
+	c.mu.RUnlock()
+	if sharedCache != nil && !opts.Dedicated {
+		sharedListeners.add(index)
+		return sharedCache, sharedListeners, true, nil
+	}
+	numCounters := opts.NumCounters
+	if numCounters <= 0 {
+		numCounters = defaultCacheNumCounters
+	}
+	maxCost := opts.MaxCost
+	if maxCost <= 0 {
+		maxCost = defaultCacheMaxCost
+	}
+	listeners = newCacheListeners()
+	listeners.add(index)
+	cache, err = newCache(numCounters, maxCost, listeners)
+	return cache, listeners, false, err
+}
+
+// SetSharedCacheBudget 设置所有集群共享的缓存容量，单位字节
+// 之后注册的集群，除非禁用缓存或设置了独立缓存，都将使用该共享缓存
+// 需在注册集群前调用
+func (c *ClusterInstances) SetSharedCacheBudget(maxCost int64) error {
+	if maxCost <= 0 {
+		return fmt.Errorf("共享缓存容量必须大于0")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sharedCache != nil {
+		c.sharedCache.UpdateMaxCost(maxCost)
+		return nil
+	}
+	listeners := newCacheListeners()
+	cache, err := newCache(sharedCacheNumCounters, maxCost, listeners)
+	if err != nil {
+		return err
+	}
+	c.sharedCache = cache
+	c.sharedCacheListeners = listeners
+	return nil
+}
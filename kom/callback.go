@@ -3,6 +3,8 @@ package kom
 import (
 	"fmt"
 	"sort"
+	"sync"
+	"time"
 
 	"k8s.io/klog/v2"
 )
@@ -13,9 +15,32 @@ type callbacks struct {
 }
 
 type processor struct {
+	name      string // 操作名称，如 get、list、update
 	km        *Kubectl
 	fns       []func(*Kubectl) error
+	fnNames   []string // 与fns对应的callback名称
 	callbacks []*callback
+
+	hookMu      sync.RWMutex
+	beforeHooks []*hook
+	afterHooks  []*hook
+	errorHooks  []*hook
+}
+
+// ExecResult 一次操作的执行结果，AfterHook、OnError 钩子使用
+type ExecResult struct {
+	Operation string        // 操作名称，如 get、list、update
+	Result    interface{}   // 执行结果，即 Statement.Dest，Delete 等操作为nil
+	Error     error         // 最终的错误，成功时为nil
+	StartTime time.Time     // 开始时间
+	Duration  time.Duration // 耗时
+}
+
+// hook 钩子，不参与callback的排序，在所有callback之前或之后执行
+type hook struct {
+	name   string
+	before func(*Kubectl) error
+	after  func(*Kubectl, *ExecResult)
 }
 type callback struct {
 	name      string
@@ -28,7 +53,7 @@ type callback struct {
 }
 
 func (k *Kubectl) initializeCallbacks() *callbacks {
-	return &callbacks{
+	cs := &callbacks{
 		processors: map[string]*processor{
 			"get":         {km: k},
 			"patch":       {km: k},
@@ -41,8 +66,13 @@ func (k *Kubectl) initializeCallbacks() *callbacks {
 			"watch":       {km: k},
 			"describe":    {km: k},
 			"stream-exec": {km: k},
+			"evict":       {km: k},
 		},
 	}
+	for name, p := range cs.processors {
+		p.name = name
+	}
+	return cs
 }
 
 func (cs *callbacks) Create() *processor {
@@ -73,12 +103,20 @@ func (cs *callbacks) Exec() *processor {
 func (cs *callbacks) StreamExec() *processor {
 	return cs.processors["stream-exec"]
 }
+func (cs *callbacks) Evict() *processor {
+	return cs.processors["evict"]
+}
 func (cs *callbacks) Logs() *processor {
 	return cs.processors["logs"]
 }
 func (cs *callbacks) Watch() *processor {
 	return cs.processors["watch"]
 }
+
+// Processor 按操作名称获取，如 get、list、stream-exec，不存在时返回nil
+func (cs *callbacks) Processor(name string) *processor {
+	return cs.processors[name]
+}
 func (c *callback) Remove(name string) error {
 	klog.V(4).Infof("removing callback `%s` \n", name)
 	c.name = name
@@ -136,16 +174,162 @@ func (p *processor) Execute(k *Kubectl) error {
 	// 	k.Statement.Error = fmt.Errorf("请先调用Resource()、CRD()、GVR()等方法指明操作对象的GVR")
 	// 	return k.Statement.Error
 	// }
+	start := time.Now()
+	beforeHooks, afterHooks, errorHooks := p.hooks()
+
+	// 本次操作的Span，执行期间的API请求均为其子Span
+	ctx, span := startSpan(k.Statement.Context, "kom."+p.name)
+	if _, ok := span.(noopSpan); !ok {
+		span.SetAttributes(k.spanAttributes()...)
+		span.SetAttributes(Attr("kom.operation", p.name))
+		// 在使用Span ctx的副本上执行，不修改调用方Statement的ctx
+		caller := k
+		k = k.withSpanContext(ctx)
+		defer func() {
+			caller.Statement.RowsAffected = k.Statement.RowsAffected
+		}()
+	}
+	defer span.End()
 
-	for _, f := range p.fns {
-		err := f(k)
+	// 模拟身份的客户端创建失败时直接返回错误
+	err := k.impersonationError()
+	for _, h := range beforeHooks {
 		if err != nil {
+			break
+		}
+		err = h.before(k)
+	}
+	if err == nil {
+		err = p.run(k)
+	}
+
+	duration := time.Since(start)
+	metricsRegistry.observe(k.ID, k.Statement.GVR, p.name, err, duration)
+	if err != nil {
+		span.SetAttributes(Attr("error", err.Error()))
+	}
+
+	if len(afterHooks) == 0 && (err == nil || len(errorHooks) == 0) {
+		return err
+	}
+	result := &ExecResult{
+		Operation: p.name,
+		Result:    k.Statement.Dest,
+		Error:     err,
+		StartTime: start,
+		Duration:  duration,
+	}
+	if err != nil {
+		for _, h := range errorHooks {
+			h.after(k, result)
+		}
+	}
+	for _, h := range afterHooks {
+		h.after(k, result)
+	}
+	return err
+}
+
+// run 依次执行callback，钩子只执行一次
+// 核心callback（如 kom:create）及之前的callback失败时按重试策略重试，从失败的callback继续执行，已成功的不再执行；
+// 核心callback成功后，之后的callback（如缓存失效）失败时不重试，变更请求不会重复发送
+func (p *processor) run(k *Kubectl) error {
+	fns, names := p.fns, p.fnNames
+	core := len(fns) - 1
+	for i, name := range names {
+		if name == "kom:"+p.name {
+			core = i
+			break
+		}
+	}
+
+	next := 0
+	err := k.withRetry(p.name, func() error {
+		for ; next <= core; next++ {
+			if err := fns[next](k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, f := range fns[core+1:] {
+		if err := f(k); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// BeforeHook 注册前置钩子，在所有callback之前执行
+// 返回错误时终止执行，该错误作为操作的结果返回，AfterHook、OnError 钩子仍会执行
+// 同名钩子重复注册时替换
+func (p *processor) BeforeHook(name string, fn func(k *Kubectl) error) {
+	p.hookMu.Lock()
+	defer p.hookMu.Unlock()
+	p.beforeHooks = setHook(p.beforeHooks, &hook{name: name, before: fn})
+}
+
+// AfterHook 注册后置钩子，在所有callback之后执行，无论成功失败都会执行
+// 通过 ExecResult 获取最终的错误、执行结果以及耗时
+func (p *processor) AfterHook(name string, fn func(k *Kubectl, result *ExecResult)) {
+	p.hookMu.Lock()
+	defer p.hookMu.Unlock()
+	p.afterHooks = setHook(p.afterHooks, &hook{name: name, after: fn})
+}
+
+// OnError 注册错误钩子，执行失败时调用，先于 AfterHook 执行
+func (p *processor) OnError(name string, fn func(k *Kubectl, result *ExecResult)) {
+	p.hookMu.Lock()
+	defer p.hookMu.Unlock()
+	p.errorHooks = setHook(p.errorHooks, &hook{name: name, after: fn})
+}
+
+// RemoveHook 删除指定名称的钩子
+func (p *processor) RemoveHook(name string) {
+	p.hookMu.Lock()
+	defer p.hookMu.Unlock()
+	p.beforeHooks = removeHook(p.beforeHooks, name)
+	p.afterHooks = removeHook(p.afterHooks, name)
+	p.errorHooks = removeHook(p.errorHooks, name)
+}
+
+func (p *processor) hooks() (beforeHooks, afterHooks, errorHooks []*hook) {
+	p.hookMu.RLock()
+	defer p.hookMu.RUnlock()
+	return p.beforeHooks, p.afterHooks, p.errorHooks
+}
+
+// setHook 添加钩子，同名时替换，返回新的切片，不修改执行中正在使用的切片
+func setHook(hooks []*hook, h *hook) []*hook {
+	result := make([]*hook, 0, len(hooks)+1)
+	replaced := false
+	for _, v := range hooks {
+		if v.name == h.name {
+			result = append(result, h)
+			replaced = true
+			continue
+		}
+		result = append(result, v)
+	}
+	if !replaced {
+		result = append(result, h)
+	}
+	return result
+}
+
+func removeHook(hooks []*hook, name string) []*hook {
+	result := make([]*hook, 0, len(hooks))
+	for _, v := range hooks {
+		if v.name != name {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
 func (p *processor) Before(name string) *callback {
 	return &callback{before: name, processor: p}
 }
@@ -173,12 +357,12 @@ func (p *processor) compile() (err error) {
 	}
 	p.callbacks = callbacks
 
-	if p.fns, err = sortCallbacks(p.callbacks); err != nil {
+	if p.fns, p.fnNames, err = sortCallbacks(p.callbacks); err != nil {
 		klog.V(4).Infof("Got error when compile callbacks, got %v", err)
 	}
 	return
 }
-func sortCallbacks(cs []*callback) (fns []func(*Kubectl) error, err error) {
+func sortCallbacks(cs []*callback) (fns []func(*Kubectl) error, fnNames []string, err error) {
 	var (
 		names, sorted []string
 		sortCallback  func(*callback) error
@@ -268,6 +452,7 @@ func sortCallbacks(cs []*callback) (fns []func(*Kubectl) error, err error) {
 	for _, name := range sorted {
 		if idx := getRIndex(names, name); !cs[idx].remove {
 			fns = append(fns, cs[idx].handler)
+			fnNames = append(fnNames, name)
 		}
 	}
 
@@ -2,19 +2,20 @@ package kom
 
 import (
 	"fmt"
-	"time"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/dgraph-io/ristretto/v2"
 	"github.com/weibaohui/kom/kom/describe"
 	"github.com/weibaohui/kom/kom/doc"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"k8s.io/klog/v2"
 )
 
@@ -22,24 +23,47 @@ var clusterInstances *ClusterInstances
 
 // ClusterInstances 集群实例管理器
 type ClusterInstances struct {
-	clusters             map[string]*ClusterInst
-	callbackRegisterFunc func(cluster *ClusterInst) func() // 用来注册回调参数的回调方法
+	mu                     sync.RWMutex
+	clusters               map[string]*ClusterInst
+	pending                map[string]*pendingRegistration   // 正在初始化的集群，避免同一ID重复初始化
+	callbackRegisterFunc   func(cluster *ClusterInst) func() // 用来注册回调参数的回调方法
+	sharedCache            *ristretto.Cache[string, any]     // 全局共享缓存，通过 SetSharedCacheBudget 设置
+	sharedCacheListeners   *cacheListeners                   // 使用共享缓存的集群索引
+	resourceChangeHandlers []func(change ResourceChange)     // API资源变更回调
+	stateChangeHandlers    []func(event StateChangeEvent)    // 集群连接状态变更回调
+	store                  ClusterStore                      // 集群定义持久化存储，通过 SetStore 设置
 }
 
 // ClusterInst 单一集群实例
 type ClusterInst struct {
-	ID            string                       // 集群ID
-	Kubectl       *Kubectl                     // kom
-	Client        *kubernetes.Clientset        // kubernetes 客户端
-	Config        *rest.Config                 // rest config
-	DynamicClient *dynamic.DynamicClient       // 动态客户端
-	apiResources  []*metav1.APIResource        // 当前k8s已注册资源
-	crdList       []*unstructured.Unstructured // 当前k8s已注册资源 //TODO 定时更新或者Watch更新
-	callbacks     *callbacks                   // 回调
-	docs          *doc.Docs                    // 文档
-	serverVersion *version.Info                // 服务器版本
-	describerMap  map[schema.GroupKind]describe.ResourceDescriber
-	Cache         *ristretto.Cache[string, any]
+	ID            string                   // 集群ID
+	Kubectl       *Kubectl                 // kom
+	Client        *kubernetes.Clientset    // kubernetes 客户端
+	Config        *rest.Config             // rest config
+	DynamicClient *dynamic.DynamicClient   // 动态客户端
+	discovery     *discoveryState          // 当前k8s已注册资源及CRD列表，Watch CRD及定时刷新
+	callbacks     *callbacks               // 回调
+	docs          lazyValue[*doc.Docs]     // 文档，首次使用时加载
+	serverVersion lazyValue[*version.Info] // 服务器版本，首次使用时获取
+	// 描述器，首次使用时初始化
+	describerMap  lazyValue[map[schema.GroupKind]describe.ResourceDescriber]
+	status        clusterStatus                 // 连接状态
+	health        *healthChecker                // 后台健康检查
+	impersonation *impersonationCache           // 按模拟身份缓存的客户端
+	Cache         *ristretto.Cache[string, any] // 查询缓存，禁用缓存时为nil
+	informers     *informerManager              // informer 缓存，FromCache 查询使用
+	cacheIndex    *cacheIndex                   // 查询缓存索引，变更资源后据此失效缓存
+	cacheListener *cacheListeners               // 缓存淘汰回调，释放缓存时移除本集群的索引
+	sharedCache   bool                          // Cache 是否为全局共享缓存
+	Options       RegisterOptions               // 注册参数
+	kubeconfig    []byte                        // 注册使用的kubeconfig内容，通过rest config注册时为空
+}
+
+// pendingRegistration 正在进行的集群注册，并发注册同一ID时等待其完成
+type pendingRegistration struct {
+	done    chan struct{}
+	kubectl *Kubectl
+	err     error
 }
 
 // Clusters 集群实例管理器
@@ -51,6 +75,7 @@ func Clusters() *ClusterInstances {
 func init() {
 	clusterInstances = &ClusterInstances{
 		clusters: make(map[string]*ClusterInst),
+		pending:  make(map[string]*pendingRegistration),
 	}
 }
 
@@ -74,103 +99,278 @@ func Cluster(id string) *Kubectl {
 }
 
 // RegisterInCluster 注册InCluster集群
-func (c *ClusterInstances) RegisterInCluster() (*Kubectl, error) {
+func (c *ClusterInstances) RegisterInCluster(opts ...RegisterOptions) (*Kubectl, error) {
 	config, err := rest.InClusterConfig()
 	if err != nil {
 		return nil, fmt.Errorf("InCluster Error %v", err)
 	}
-	return c.RegisterByConfigWithID(config, "InCluster")
+	return c.RegisterByConfigWithID(config, "InCluster", opts...)
 }
 
 // SetRegisterCallbackFunc 设置回调注册函数
 func (c *ClusterInstances) SetRegisterCallbackFunc(callback func(cluster *ClusterInst) func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.callbackRegisterFunc = callback
 }
 
+// GetRegisterCallbackFunc 获取当前的回调注册函数，插件可据此在原有函数的基础上追加注册
+func (c *ClusterInstances) GetRegisterCallbackFunc() func(cluster *ClusterInst) func() {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.callbackRegisterFunc
+}
+
 // RegisterByPath 通过kubeconfig文件路径注册集群
-func (c *ClusterInstances) RegisterByPath(path string) (*Kubectl, error) {
+func (c *ClusterInstances) RegisterByPath(path string, opts ...RegisterOptions) (*Kubectl, error) {
 	config, err := clientcmd.BuildConfigFromFlags("", path)
-	config.QPS = 200
-	config.Burst = 2000
 	if err != nil {
 		return nil, fmt.Errorf("RegisterByPath Error %s %v", path, err)
 	}
-	return c.RegisterByConfig(config)
+	return c.register(config, config.Host, contextKubeconfig(path, ""), withPathDefaults(opts), true)
 }
 
 // RegisterByPathWithID 通过kubeconfig文件路径注册集群
-func (c *ClusterInstances) RegisterByPathWithID(path string, id string) (*Kubectl, error) {
+func (c *ClusterInstances) RegisterByPathWithID(path string, id string, opts ...RegisterOptions) (*Kubectl, error) {
 	config, err := clientcmd.BuildConfigFromFlags("", path)
-	config.QPS = 200
-	config.Burst = 2000
 	if err != nil {
 		return nil, fmt.Errorf("RegisterByPathWithID Error path:%s,id:%s,err:%v", path, id, err)
 	}
-	return c.RegisterByConfigWithID(config, id)
+	return c.register(config, id, contextKubeconfig(path, ""), withPathDefaults(opts), true)
+}
+
+// RegisterByKubeconfigBytes 通过kubeconfig内容注册集群，使用其中的当前上下文
+// 适用于kubeconfig保存在数据库等非文件的场景
+func (c *ClusterInstances) RegisterByKubeconfigBytes(data []byte, id string, opts ...RegisterOptions) (*Kubectl, error) {
+	config, err := clientcmd.RESTConfigFromKubeConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("RegisterByKubeconfigBytes Error id:%s,err:%v", id, err)
+	}
+	kubeconfig, err := clientcmd.Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("RegisterByKubeconfigBytes Error id:%s,err:%v", id, err)
+	}
+	return c.register(config, id, minifyKubeconfig(kubeconfig, ""), withPathDefaults(opts), true)
+}
+
+// RegisterByContext 通过kubeconfig文件中指定的上下文注册集群
+// id 为空时使用上下文名称作为集群ID
+func (c *ClusterInstances) RegisterByContext(path string, contextName string, id string, opts ...RegisterOptions) (*Kubectl, error) {
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: path},
+		&clientcmd.ConfigOverrides{CurrentContext: contextName},
+	).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("RegisterByContext Error path:%s,context:%s,err:%v", path, contextName, err)
+	}
+	if id == "" {
+		id = contextName
+	}
+	return c.register(config, id, contextKubeconfig(path, contextName), withPathDefaults(opts), true)
+}
+
+// contextKubeconfig 只保留指定上下文的kubeconfig内容，用于持久化集群定义，失败时返回nil
+// contextName 为空时使用当前上下文
+func contextKubeconfig(path string, contextName string) []byte {
+	if path == "" {
+		return nil
+	}
+	kubeconfig, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return nil
+	}
+	return minifyKubeconfig(kubeconfig, contextName)
+}
+
+// minifyKubeconfig 只保留指定上下文及其引用的集群、用户，证书等文件内容内联到kubeconfig中
+// 避免持久化无关的上下文及凭证，失败时返回nil
+func minifyKubeconfig(kubeconfig *clientcmdapi.Config, contextName string) []byte {
+	if contextName != "" {
+		kubeconfig.CurrentContext = contextName
+	}
+	if err := clientcmdapi.MinifyConfig(kubeconfig); err != nil {
+		return nil
+	}
+	if err := clientcmdapi.FlattenConfig(kubeconfig); err != nil {
+		return nil
+	}
+	data, err := clientcmd.Write(*kubeconfig)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// RegisterAllContexts 注册kubeconfig文件中的所有上下文，使用上下文名称作为集群ID
+// 部分上下文注册失败时，其余上下文仍会注册，返回已注册成功的集群以及失败的原因
+func (c *ClusterInstances) RegisterAllContexts(path string, opts ...RegisterOptions) (map[string]*Kubectl, error) {
+	kubeconfig, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("RegisterAllContexts Error path:%s,err:%v", path, err)
+	}
+	names := make([]string, 0, len(kubeconfig.Contexts))
+	for name := range kubeconfig.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make(map[string]*Kubectl, len(names))
+	var errs []string
+	for _, name := range names {
+		k, err := c.RegisterByContext(path, name, name, opts...)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		result[name] = k
+	}
+	if len(errs) > 0 {
+		return result, fmt.Errorf("RegisterAllContexts Error path:%s,errs:[%s]", path, strings.Join(errs, "; "))
+	}
+	return result, nil
 }
 
 // RegisterByConfig 注册集群
-func (c *ClusterInstances) RegisterByConfig(config *rest.Config) (*Kubectl, error) {
+func (c *ClusterInstances) RegisterByConfig(config *rest.Config, opts ...RegisterOptions) (*Kubectl, error) {
 	if config == nil {
 		return nil, fmt.Errorf("config is nil")
 	}
 	host := config.Host
 
-	return c.RegisterByConfigWithID(config, host)
+	return c.RegisterByConfigWithID(config, host, opts...)
 }
 
 // RegisterByConfigWithID 注册集群
-func (c *ClusterInstances) RegisterByConfigWithID(config *rest.Config, id string) (*Kubectl, error) {
+// 同一ID已注册时直接返回；同一ID正在注册时，等待其完成并返回相同结果
+func (c *ClusterInstances) RegisterByConfigWithID(config *rest.Config, id string, opts ...RegisterOptions) (*Kubectl, error) {
+	return c.register(config, id, nil, getRegisterOptions(opts), true)
+}
+
+// register 注册集群，kubeconfig 不为空且 persist 为true时，设置了存储则保存集群定义
+// 从存储中加载的集群 persist 为false，避免每次启动都重写存储
+func (c *ClusterInstances) register(config *rest.Config, id string, kubeconfig []byte, options RegisterOptions, persist bool) (*Kubectl, error) {
 	if config == nil {
 		return nil, fmt.Errorf("config is nil")
 	}
 
-	cluster, exists := clusterInstances.clusters[id]
-	if exists {
+	c.mu.Lock()
+	if cluster, exists := c.clusters[id]; exists {
+		c.mu.Unlock()
 		return cluster.Kubectl, nil
-	} else {
-		// key 不存在，进行初始化
-		k := initKubectl(config, id)
-		cluster = &ClusterInst{
-			ID:      id,
-			Kubectl: k,
-			Config:  config,
-		}
-		clusterInstances.clusters[id] = cluster
+	}
+	if p, exists := c.pending[id]; exists {
+		c.mu.Unlock()
+		<-p.done
+		return p.kubectl, p.err
+	}
+	p := &pendingRegistration{done: make(chan struct{})}
+	c.pending[id] = p
+	c.mu.Unlock()
 
-		client, err := kubernetes.NewForConfig(config)
-		if err != nil {
-			return nil, fmt.Errorf("RegisterByConfigWithID Error %s %v", id, err)
-		}
-		dynamicClient, err := dynamic.NewForConfig(config)
-		if err != nil {
-			return nil, fmt.Errorf("RegisterByConfigWithID Error %s %v", id, err)
-		}
-		cluster.Client = client               // kubernetes 客户端
-		cluster.DynamicClient = dynamicClient // 动态客户端
-		// 缓存
-		cluster.apiResources = k.initializeAPIResources()       // API 资源
-		cluster.crdList = k.initializeCRDList(time.Minute * 10) // CRD列表,10分钟缓存
-		cluster.callbacks = k.initializeCallbacks()             // 回调
-		cluster.serverVersion = k.initializeServerVersion()     // 服务器版本
-		cluster.docs = doc.InitTrees(k.getOpenAPISchema())      // 文档
-		cluster.describerMap = k.initializeDescriberMap()       // 初始化描述器
-		if c.callbackRegisterFunc != nil {                      // 注册回调方法
-			c.callbackRegisterFunc(cluster)
-		}
+	// key 不存在，进行初始化
+	// 初始化完成后才加入集群列表，失败时不会留下不完整的集群
+	cluster, err := c.initCluster(config, id, options)
 
-		cache, err := ristretto.NewCache(&ristretto.Config[string, any]{
-			NumCounters: 1e7,     // number of keys to track frequency of (10M).
-			MaxCost:     1 << 30, // maximum cost of cache (1GB).
-			BufferItems: 64,      // number of keys per Get buffer.
-		})
-		cluster.Cache = cache
-		return k, nil
+	c.mu.Lock()
+	delete(c.pending, id)
+	if err == nil {
+		cluster.kubeconfig = kubeconfig
+		c.clusters[id] = cluster
+		p.kubectl = cluster.Kubectl
+	}
+	p.err = err
+	c.mu.Unlock()
+	close(p.done)
+	if err != nil {
+		return nil, err
+	}
+	if persist {
+		c.saveToStore(cluster)
+	}
+	return cluster.Kubectl, nil
+}
+
+// initCluster 初始化集群实例
+func (c *ClusterInstances) initCluster(config *rest.Config, id string, options RegisterOptions) (*ClusterInst, error) {
+	// 复制一份，避免注册参数修改调用方传入的config
+	config = rest.CopyConfig(config)
+	if err := options.applyToConfig(config); err != nil {
+		return nil, fmt.Errorf("RegisterByConfigWithID Error %s %v", id, err)
+	}
+	// 为每个API请求创建Span，通过 SetTracer 启用
+	config.Wrap(newTracingRoundTripper(id))
+	cluster := &ClusterInst{
+		ID:      id,
+		Config:  config,
+		Options: options,
+	}
+	k := initKubectl(config, id, cluster)
+	cluster.Kubectl = k
+	cluster.cacheIndex = newCacheIndex()
+	cache, listeners, shared, err := c.newClusterCache(options.Cache, cluster.cacheIndex)
+	if err != nil {
+		return nil, fmt.Errorf("RegisterByConfigWithID Error %s %v", id, err)
+	}
+	cluster.Cache = cache
+	cluster.cacheListener = listeners
+	cluster.sharedCache = shared
+	if err := c.initClusterClients(cluster); err != nil {
+		cluster.releaseCache()
+		return nil, err
+	}
+	cluster.informers = newInformerManager(k)
+	cluster.impersonation = newImpersonationCache()
+	cluster.discovery = newDiscoveryState()     // API 资源及CRD列表，首次使用时获取
+	cluster.callbacks = k.initializeCallbacks() // 回调
+	c.mu.RLock()
+	callbackRegisterFunc := c.callbackRegisterFunc
+	c.mu.RUnlock()
+	if callbackRegisterFunc != nil { // 注册回调方法
+		callbackRegisterFunc(cluster)
+	}
+	// 注册不访问API Server，集群不可达时也能注册成功
+	go cluster.warmUp()
+	cluster.health = newHealthChecker()
+	if !options.DisableHealthCheck {
+		cluster.startHealthCheck(options.HealthCheckInterval)
+	}
+	return cluster, nil
+}
+
+// initClusterClients 创建集群客户端
+func (c *ClusterInstances) initClusterClients(cluster *ClusterInst) error {
+	client, err := kubernetes.NewForConfig(cluster.Config)
+	if err != nil {
+		return fmt.Errorf("RegisterByConfigWithID Error %s %v", cluster.ID, err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(cluster.Config)
+	if err != nil {
+		return fmt.Errorf("RegisterByConfigWithID Error %s %v", cluster.ID, err)
+	}
+	cluster.Client = client               // kubernetes 客户端
+	cluster.DynamicClient = dynamicClient // 动态客户端
+	return nil
+}
+
+// releaseCache 释放集群查询缓存
+// 共享缓存只删除本集群的key，独立缓存直接关闭
+func (ci *ClusterInst) releaseCache() {
+	if ci.cacheListener != nil {
+		ci.cacheListener.remove(ci.cacheIndex)
+	}
+	if ci.sharedCache {
+		for _, key := range ci.cacheIndex.reset() {
+			ci.Cache.Del(key)
+		}
+	} else if ci.Cache != nil {
+		ci.Cache.Close()
 	}
 }
 
 // GetClusterById 根据集群ID获取集群实例
 func (c *ClusterInstances) GetClusterById(id string) *ClusterInst {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	cluster, exists := c.clusters[id]
 	if !exists {
 		return nil
@@ -180,12 +380,36 @@ func (c *ClusterInstances) GetClusterById(id string) *ClusterInst {
 
 // RemoveClusterById 删除集群
 func (c *ClusterInstances) RemoveClusterById(id string) {
+	c.mu.Lock()
+	cluster, exists := c.clusters[id]
 	delete(c.clusters, id)
+	c.mu.Unlock()
+	if !exists {
+		return
+	}
+	c.deleteFromStore(id)
+	if cluster.informers != nil {
+		cluster.informers.StopAll()
+	}
+	if cluster.discovery != nil {
+		cluster.discovery.stop()
+	}
+	if cluster.health != nil {
+		cluster.health.stop()
+	}
+	cluster.releaseCache()
 }
 
 // AllClusters 返回所有集群实例
+// 返回的是当前集群列表的副本，修改该map不影响已注册的集群
 func (c *ClusterInstances) AllClusters() map[string]*ClusterInst {
-	return c.clusters
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	clusters := make(map[string]*ClusterInst, len(c.clusters))
+	for id, cluster := range c.clusters {
+		clusters[id] = cluster
+	}
+	return clusters
 }
 
 // DefaultCluster 返回一个默认的 ClusterInst 实例。
@@ -194,6 +418,8 @@ func (c *ClusterInstances) AllClusters() map[string]*ClusterInst {
 // 则尝试返回 ID 为 "default" 的实例。
 // 如果上述两个实例都不存在，则返回 clusters 列表中的任意一个实例。
 func (c *ClusterInstances) DefaultCluster() *ClusterInst {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	// 检查 clusters 列表是否为空
 	if len(c.clusters) == 0 {
 		return nil
@@ -225,11 +451,13 @@ func (c *ClusterInstances) DefaultCluster() *ClusterInst {
 // Show 显示所有集群信息
 func (c *ClusterInstances) Show() {
 	klog.Infof("Show Clusters\n")
-	for k, v := range c.clusters {
-		if v.serverVersion == nil {
-			klog.Infof("%s=nil\n", k)
+	for k, v := range c.AllClusters() {
+		health := v.Health()
+		serverVersion, loaded := v.serverVersion.peek()
+		if !loaded {
+			klog.Infof("%s[%s,latency=%s,error=%s]=%s\n", k, health.State, health.Latency, health.Error, v.Config.Host)
 			continue
 		}
-		klog.Infof("%s[%s,%s]=%s\n", k, v.serverVersion.Platform, v.serverVersion.GitVersion, v.Config.Host)
+		klog.Infof("%s[%s,%s,%s,latency=%s]=%s\n", k, health.State, serverVersion.Platform, serverVersion.GitVersion, health.Latency, v.Config.Host)
 	}
 }
@@ -25,6 +25,10 @@ func (d *deploy) Scale(replicas int32) error {
 	return d.kubectl.Ctl().Scale(replicas)
 }
 func (d *deploy) ManagedPods() ([]*corev1.Pod, error) {
+	// 内部的多次请求均为该Span的子Span
+	kubectl, span := d.kubectl.startSpan("kom.deploy.ManagedPods")
+	defer span.End()
+	d = &deploy{kubectl: kubectl}
 	//先找到rs
 	rs, err := d.ManagedLatestReplicaSet()
 	if err != nil {
@@ -100,19 +104,16 @@ func (d *deploy) ManagedLatestReplicaSet() (*v1.ReplicaSet, error) {
 
 func (d *deploy) ReplaceImageTag(targetContainerName string, tag string) (*v1.Deployment, error) {
 	var item v1.Deployment
-	err := d.kubectl.Resource(&item).Get(&item).Error
-
-	if err != nil {
-		return nil, err
-	}
-
-	for i := range item.Spec.Template.Spec.Containers {
-		c := &item.Spec.Template.Spec.Containers[i]
-		if c.Name == targetContainerName {
-			c.Image = replaceImageTag(c.Image, tag)
+	// 冲突时重新获取最新的Deployment再替换
+	err := d.kubectl.Resource(&item).GetAndUpdate(&item, func(obj interface{}) error {
+		for i := range item.Spec.Template.Spec.Containers {
+			c := &item.Spec.Template.Spec.Containers[i]
+			if c.Name == targetContainerName {
+				c.Image = replaceImageTag(c.Image, tag)
+			}
 		}
-	}
-	err = d.kubectl.Resource(&item).Update(&item).Error
+		return nil
+	}).Error
 	return &item, err
 }
 
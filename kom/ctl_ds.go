@@ -59,6 +59,10 @@ func (d *daemonSet) Restore() error {
 }
 
 func (d *daemonSet) ManagedPods() ([]*corev1.Pod, error) {
+	// 内部的多次请求均为该Span的子Span
+	kubectl, span := d.kubectl.startSpan("kom.daemonset.ManagedPods")
+	defer span.End()
+	d = &daemonSet{kubectl: kubectl}
 	//先找到ds
 	var ds v1.DaemonSet
 	err := d.kubectl.WithCache(d.kubectl.Statement.CacheTTL).Resource(&ds).Get(&ds).Error
@@ -1,7 +1,6 @@
 package kom
 
 import (
-	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -10,9 +9,7 @@ import (
 	"github.com/duke-git/lancet/v2/slice"
 	"github.com/weibaohui/kom/utils"
 	corev1 "k8s.io/api/core/v1"
-	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
@@ -118,6 +115,10 @@ func (d *node) AllNodeLabels() (map[string]string, error) {
 // drain 通常在节点需要进行维护时使用。它不仅会标记节点为不可调度，还会逐一驱逐（Evict）该节点上的所有 Pod。
 func (d *node) Drain() error {
 	// todo 增加--force的处理，也就强制驱逐所有pod，即便是不满足PDB
+	// 内部的多次请求均为该Span的子Span
+	kubectl, span := d.kubectl.startSpan("kom.node.Drain")
+	defer span.End()
+	d = &node{kubectl: kubectl}
 	name := d.kubectl.Statement.Name
 
 	// Step 1: 将节点标记为不可调度
@@ -211,15 +212,10 @@ func isMirrorPod(pod *corev1.Pod) bool {
 // 驱逐 Pod
 func (d *node) evictPod(pod *corev1.Pod) error {
 	klog.V(8).Infof("evicting pod %s/%s \n", pod.Namespace, pod.Name)
-	eviction := &policyv1.Eviction{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      pod.Name,
-			Namespace: pod.Namespace,
-		},
-	}
-	err := d.kubectl.Client().PolicyV1().Evictions(pod.Namespace).Evict(context.TODO(), eviction)
-
-	// err := d.kubectl.newInstance().Resource(eviction).Create(eviction).Error
+	// 经过回调执行，试运行、钩子（审计、策略）及缓存失效与其他变更操作一致
+	tx := d.kubectl.newInstance().Resource(&corev1.Pod{}).Namespace(pod.Namespace).Name(pod.Name)
+	tx.Error = tx.Callback().Evict().Execute(tx)
+	err := tx.Error
 	if err != nil {
 		return err
 	}
@@ -199,6 +199,10 @@ func (p *pod) LinkedPV() ([]*v1.PersistentVolume, error) {
 }
 
 func (p *pod) LinkedIngress() ([]*networkingv1.Ingress, error) {
+	// 内部的多次请求均为该Span的子Span
+	kubectl, span := p.kubectl.startSpan("kom.pod.LinkedIngress")
+	defer span.End()
+	p = &pod{kubectl: kubectl, Error: p.Error}
 
 	var pod v1.Pod
 	err := p.kubectl.Get(&pod).Error
@@ -471,8 +471,11 @@ func (d *rollout) rollbackDeployment(toVersion int) error {
 	}
 	spec := vrs.Spec.Template.Spec
 
-	deploy.Spec.Template.Spec = spec
-	err = d.kubectl.Resource(&deploy).Update(&deploy).Error
+	// 冲突时重新获取最新的Deployment再回滚
+	err = d.kubectl.Resource(&deploy).GetAndUpdate(&deploy, func(obj interface{}) error {
+		deploy.Spec.Template.Spec = spec
+		return nil
+	}).Error
 	if err != nil {
 		return fmt.Errorf(" rollbackDeployment rollout undo deployment  err %v ", err)
 	}
@@ -582,11 +585,11 @@ func (d *rollout) rollbackDaemonSet(toVersion int) error {
 		return fmt.Errorf("rollbackDaemonSet unmarshal controllerrevision data err %v", err)
 	}
 
-	// 使用目标版本的模板更新当前 DaemonSet
-	ds.Spec.Template.Spec = dsTemplate.Spec.Template.Spec
-
-	// 更新 DaemonSet
-	err = d.kubectl.Resource(&ds).Update(&ds).Error
+	// 使用目标版本的模板更新当前 DaemonSet，冲突时重新获取最新的DaemonSet再更新
+	err = d.kubectl.Resource(&ds).GetAndUpdate(&ds, func(obj interface{}) error {
+		ds.Spec.Template.Spec = dsTemplate.Spec.Template.Spec
+		return nil
+	}).Error
 	if err != nil {
 		return fmt.Errorf("rollbackDaemonSet update daemonset err %v", err)
 	}
@@ -671,11 +674,11 @@ func (d *rollout) rollbackStatefulSet(toVersion int) error {
 		return fmt.Errorf("rollbackStatefulSet unmarshal controllerrevision data err %v", err)
 	}
 
-	// 使用目标版本的模板更新当前 DaemonSet
-	sts.Spec.Template.Spec = stsTemplate.Spec.Template.Spec
-
-	// 更新 DaemonSet
-	err = d.kubectl.Resource(&sts).Update(&sts).Error
+	// 使用目标版本的模板更新当前 StatefulSet，冲突时重新获取最新的StatefulSet再更新
+	err = d.kubectl.Resource(&sts).GetAndUpdate(&sts, func(obj interface{}) error {
+		sts.Spec.Template.Spec = stsTemplate.Spec.Template.Spec
+		return nil
+	}).Error
 	if err != nil {
 		return fmt.Errorf("rollbackStatefulSet update daemonset err %v", err)
 	}
@@ -25,6 +25,10 @@ func (r *replicaSet) Restore() error {
 }
 
 func (r *replicaSet) ManagedPods() ([]*corev1.Pod, error) {
+	// 内部的多次请求均为该Span的子Span
+	kubectl, span := r.kubectl.startSpan("kom.replicaset.ManagedPods")
+	defer span.End()
+	r = &replicaSet{kubectl: kubectl}
 	//先找到rs
 	var rs v1.ReplicaSet
 	err := r.kubectl.WithCache(r.kubectl.Statement.CacheTTL).Resource(&rs).Get(&rs).Error
@@ -26,6 +26,10 @@ func (s *statefulSet) Restore() error {
 }
 
 func (s *statefulSet) ManagedPods() ([]*corev1.Pod, error) {
+	// 内部的多次请求均为该Span的子Span
+	kubectl, span := s.kubectl.startSpan("kom.statefulset.ManagedPods")
+	defer span.End()
+	s = &statefulSet{kubectl: kubectl}
 	//先找到sts
 	var sts v1.StatefulSet
 	err := s.kubectl.WithCache(s.kubectl.Statement.CacheTTL).Resource(&sts).Get(&sts).Error
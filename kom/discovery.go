@@ -0,0 +1,350 @@
+package kom
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weibaohui/kom/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// 默认定期刷新API资源的间隔
+	defaultDiscoveryRefreshInterval = 5 * time.Minute
+	// CRD变更后延迟刷新，合并短时间内的多次变更
+	discoveryRefreshDelay = 2 * time.Second
+	// 获取失败后的重试间隔，连续失败时翻倍，直至最大间隔
+	discoveryRetryInterval    = 2 * time.Second
+	discoveryRetryMaxInterval = time.Minute
+)
+
+var crdGVR = schema.GroupVersionResource{
+	Group:    "apiextensions.k8s.io",
+	Version:  "v1",
+	Resource: "customresourcedefinitions",
+}
+
+// ResourceChange API资源变更事件
+type ResourceChange struct {
+	ClusterID string                `json:"clusterID"`
+	Added     []*metav1.APIResource `json:"added,omitempty"`   // 新出现的资源
+	Removed   []*metav1.APIResource `json:"removed,omitempty"` // 消失的资源
+}
+
+// discoveryState 集群API资源及CRD列表，支持后台刷新
+type discoveryState struct {
+	mu           sync.RWMutex
+	apiResources []*metav1.APIResource        // 当前k8s已注册资源
+	crdList      []*unstructured.Unstructured // 当前k8s已注册CRD
+	loaded       bool                         // 是否已成功获取过
+	failures     int                          // 连续失败次数，成功后清零
+	failedAt     time.Time                    // 最近一次失败的时间
+	failErr      error                        // 最近一次失败的错误，重试间隔内直接返回
+	refreshMu    sync.Mutex                   // 保证同一时间只有一个刷新在执行
+	stopCh       chan struct{}                // 停止后台刷新
+	stopOnce     sync.Once
+}
+
+func newDiscoveryState() *discoveryState {
+	return &discoveryState{
+		stopCh: make(chan struct{}),
+	}
+}
+
+func (d *discoveryState) get() ([]*metav1.APIResource, []*unstructured.Unstructured) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.apiResources, d.crdList
+}
+
+func (d *discoveryState) set(apiResources []*metav1.APIResource, crdList []*unstructured.Unstructured) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.apiResources = apiResources
+	d.crdList = crdList
+	d.loaded = true
+	d.failures = 0
+	d.failErr = nil
+}
+
+// fail 记录获取失败，之后的重试间隔内不再访问API Server
+func (d *discoveryState) fail(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.failures++
+	d.failedAt = time.Now()
+	d.failErr = err
+}
+
+// recentFailure 仍在重试间隔内时返回最近一次失败的错误
+func (d *discoveryState) recentFailure() error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.failErr == nil {
+		return nil
+	}
+	interval := discoveryRetryInterval
+	for i := 1; i < d.failures && interval < discoveryRetryMaxInterval; i++ {
+		interval *= 2
+	}
+	interval = min(interval, discoveryRetryMaxInterval)
+	if time.Since(d.failedAt) < interval {
+		return d.failErr
+	}
+	return nil
+}
+
+func (d *discoveryState) isLoaded() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.loaded
+}
+
+// stop 停止后台刷新
+func (d *discoveryState) stop() {
+	d.stopOnce.Do(func() {
+		close(d.stopCh)
+	})
+}
+
+// OnResourceChange 注册API资源变更的回调，集群内资源出现或消失时调用
+func (c *ClusterInstances) OnResourceChange(handler func(change ResourceChange)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resourceChangeHandlers = append(c.resourceChangeHandlers, handler)
+}
+
+// RefreshDiscovery 重新获取集群的API资源以及CRD列表
+// 发现资源有增减时，调用 OnResourceChange 注册的回调
+func (s *status) RefreshDiscovery() error {
+	return s.kubectl.refreshDiscovery()
+}
+
+func (k *Kubectl) refreshDiscovery() error {
+	cluster := k.parentCluster()
+	cluster.discovery.refreshMu.Lock()
+	defer cluster.discovery.refreshMu.Unlock()
+
+	oldResources, _ := cluster.discovery.get()
+	// 使用集群自身的凭据，避免模拟用户的权限范围覆盖共享的发现结果
+	apiResources, failedGroups, err := cluster.Kubectl.discoverAPIResources()
+	if err != nil {
+		err = fmt.Errorf("refresh discovery of cluster %s error: %v", k.ID, err)
+		cluster.setState(ClusterStateDisconnected, err)
+		return err
+	}
+	// 获取失败的分组，沿用之前的结果，避免误判为资源消失
+	for _, r := range oldResources {
+		if failedGroups[schema.GroupVersion{Group: r.Group, Version: r.Version}] {
+			apiResources = append(apiResources, r)
+		}
+	}
+
+	crdList, err := cluster.Kubectl.listCRDs(context.TODO())
+	if err != nil {
+		err = fmt.Errorf("refresh crd list of cluster %s error: %v", k.ID, err)
+		cluster.setState(ClusterStateDisconnected, err)
+		return err
+	}
+	cluster.discovery.set(apiResources, crdList)
+	cluster.setState(ClusterStateConnected, nil)
+	if cluster.Cache != nil {
+		cluster.Cache.Del(k.crdListCacheKey())
+	}
+
+	added, removed := diffAPIResources(oldResources, apiResources)
+	klog.V(4).Infof("cluster %s discovery refreshed, %d resources, %d crds, added %d, removed %d",
+		k.ID, len(apiResources), len(crdList), len(added), len(removed))
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+	change := ResourceChange{
+		ClusterID: k.ID,
+		Added:     added,
+		Removed:   removed,
+	}
+	c := Clusters()
+	c.mu.RLock()
+	handlers := c.resourceChangeHandlers
+	c.mu.RUnlock()
+	for _, handler := range handlers {
+		handler(change)
+	}
+	return nil
+}
+
+// ensureDiscovery 确保已获取集群的API资源及CRD列表
+// 未获取或上次获取失败时重新获取，并发调用时只有一个会访问API Server
+// 获取失败后的重试间隔内直接返回上次的错误，避免集群不可达时每次调用都同步访问API Server
+func (k *Kubectl) ensureDiscovery() error {
+	cluster := k.parentCluster()
+	if cluster.discovery.isLoaded() {
+		return nil
+	}
+	if err := cluster.discovery.recentFailure(); err != nil {
+		return err
+	}
+	cluster.discovery.refreshMu.Lock()
+	defer cluster.discovery.refreshMu.Unlock()
+	if cluster.discovery.isLoaded() {
+		return nil
+	}
+	if err := cluster.discovery.recentFailure(); err != nil {
+		return err
+	}
+	// 使用集群自身的凭据，模拟用户无权限时不应将整个集群标记为断开
+	apiResources, _, err := cluster.Kubectl.discoverAPIResources()
+	if err != nil {
+		err = fmt.Errorf("discovery of cluster %s error: %v", k.ID, err)
+		cluster.discovery.fail(err)
+		cluster.setState(ClusterStateDisconnected, err)
+		return err
+	}
+	crdList, err := cluster.Kubectl.initializeCRDList(time.Minute * 10) // CRD列表,10分钟缓存
+	if err != nil {
+		err = fmt.Errorf("list crd of cluster %s error: %v", k.ID, err)
+		cluster.discovery.fail(err)
+		cluster.setState(ClusterStateDisconnected, err)
+		return err
+	}
+	cluster.discovery.set(apiResources, crdList)
+	cluster.setState(ClusterStateConnected, nil)
+	return nil
+}
+
+// discoverAPIResources 获取集群的API资源，同时返回获取失败的分组
+func (k *Kubectl) discoverAPIResources() (apiResources []*metav1.APIResource, failedGroups map[schema.GroupVersion]bool, err error) {
+	failedGroups = map[schema.GroupVersion]bool{}
+	_, lists, err := k.Client().Discovery().ServerGroupsAndResources()
+	if err != nil {
+		if groupErr, ok := err.(*discovery.ErrGroupDiscoveryFailed); ok {
+			// 部分分组失败，如metrics-server不可用，不影响其他资源
+			for gv := range groupErr.Groups {
+				failedGroups[gv] = true
+			}
+		} else if len(lists) == 0 {
+			return nil, nil, err
+		}
+	}
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			resource.Group = gv.Group
+			resource.Version = gv.Version
+			apiResources = append(apiResources, &resource)
+		}
+	}
+	return apiResources, failedGroups, nil
+}
+
+// listCRDs 直接从API Server获取CRD列表，不经过缓存
+// 不依赖已获取的API资源，首次获取时也可使用
+func (k *Kubectl) listCRDs(ctx context.Context) ([]*unstructured.Unstructured, error) {
+	list, err := k.DynamicClient().Resource(crdGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var crdList []*unstructured.Unstructured
+	for _, item := range list.Items {
+		obj := item.DeepCopy()
+		utils.RemoveManagedFields(obj)
+		crdList = append(crdList, obj)
+	}
+	return crdList, nil
+}
+
+// diffAPIResources 对比前后两次的API资源，返回新增及消失的资源
+func diffAPIResources(oldList, newList []*metav1.APIResource) (added, removed []*metav1.APIResource) {
+	key := func(r *metav1.APIResource) string {
+		return strings.Join([]string{r.Group, r.Version, r.Name}, "/")
+	}
+	oldMap := make(map[string]*metav1.APIResource, len(oldList))
+	for _, r := range oldList {
+		oldMap[key(r)] = r
+	}
+	newMap := make(map[string]*metav1.APIResource, len(newList))
+	for _, r := range newList {
+		newMap[key(r)] = r
+		if _, ok := oldMap[key(r)]; !ok {
+			added = append(added, r)
+		}
+	}
+	for _, r := range oldList {
+		if _, ok := newMap[key(r)]; !ok {
+			removed = append(removed, r)
+		}
+	}
+	return added, removed
+}
+
+// startDiscoveryWatch 启动后台刷新
+// Watch CRD的变更，变更后延迟刷新；同时按interval定期刷新，发现聚合API等非CRD资源的变化
+func (k *Kubectl) startDiscoveryWatch(interval time.Duration) {
+	cluster := k.parentCluster()
+	stopCh := cluster.discovery.stopCh
+	if interval <= 0 {
+		interval = defaultDiscoveryRefreshInterval
+	}
+
+	refreshCh := make(chan struct{}, 1)
+	schedule := func() {
+		select {
+		case refreshCh <- struct{}{}:
+		default:
+		}
+	}
+
+	informer := dynamicinformer.NewFilteredDynamicInformer(k.DynamicClient(), crdGVR, metav1.NamespaceAll, 0, cache.Indexers{}, nil)
+	_, err := informer.Informer().AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if !isInInitialList {
+				schedule()
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			schedule()
+		},
+		DeleteFunc: func(obj interface{}) {
+			schedule()
+		},
+	})
+	if err != nil {
+		klog.V(2).Infof("cluster %s add crd event handler error: %v", k.ID, err)
+	}
+	go informer.Informer().Run(stopCh)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				schedule()
+			case <-refreshCh:
+				// 等待一段时间，合并连续的变更，同时等待CRD的资源注册到API Server
+				select {
+				case <-stopCh:
+					return
+				case <-time.After(discoveryRefreshDelay):
+				}
+				if err := k.refreshDiscovery(); err != nil {
+					klog.V(2).Infof("%v", err)
+				}
+			}
+		}
+	}()
+}
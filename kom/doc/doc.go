@@ -10,8 +10,6 @@ import (
 	"k8s.io/klog/v2"
 )
 
-var trees []TreeNode
-
 type Docs struct {
 	Trees []TreeNode
 }
@@ -96,8 +94,12 @@ type Definitions struct {
 	AdditionalProperties []map[string]interface{} `json:"additional_properties"`
 }
 
-// definitionsMap 存储所有定义，以便处理引用
-var definitionsMap map[string]SchemaDefinition
+// treeBuilder 构建文档树时的中间状态，每次 InitTrees 独立创建
+// 多个集群可能同时加载文档，不能使用包级变量
+type treeBuilder struct {
+	definitionsMap map[string]SchemaDefinition // 存储所有定义，以便处理引用
+	trees          []TreeNode
+}
 
 var blackList = []string{
 	"#/definitions/io.k8s.apiextensions-apiserver.pkg.apis.apiextensions.v1.JSONSchemaProps",
@@ -116,17 +118,17 @@ var blackList = []string{
 //			  },
 //			  "vendor_extension": [ {},{}]
 //			}
-func parseOpenAPISchema(schemaJSON string) (TreeNode, error) {
+func (b *treeBuilder) parseOpenAPISchema(schemaJSON string) (TreeNode, error) {
 	var def SchemaDefinition
 	err := json.Unmarshal([]byte(schemaJSON), &def)
 	if err != nil {
 		return TreeNode{}, err
 	}
 	// klog.V(2).Infof("add def cache %s", def.Name)
-	definitionsMap[def.Name] = def
-	// klog.V(2).Infof("add def length %d", len(definitionsMap))
+	b.definitionsMap[def.Name] = def
+	// klog.V(2).Infof("add def length %d", len(b.definitionsMap))
 
-	return buildTree(def, ""), nil
+	return b.buildTree(def, ""), nil
 }
 func parseID(id string) (group, version, kind string) {
 	parts := strings.Split(id, ".")
@@ -150,7 +152,7 @@ func parseID(id string) (group, version, kind string) {
 }
 
 // buildTree 根据 SchemaDefinition 构建 TreeNode
-func buildTree(def SchemaDefinition, parentId string) TreeNode {
+func (b *treeBuilder) buildTree(def SchemaDefinition, parentId string) TreeNode {
 	// todo 应该使用GVK作为
 	klog.V(6).Infof("buildTree %s", def.Name)
 
@@ -164,7 +166,7 @@ func buildTree(def SchemaDefinition, parentId string) TreeNode {
 	var children []*TreeNode
 
 	for _, prop := range def.Value.Properties.AdditionalProperties {
-		children = append(children, buildPropertyNode(prop, def.Name))
+		children = append(children, b.buildPropertyNode(prop, def.Name))
 	}
 
 	group, version, kind := parseID(def.Name)
@@ -184,7 +186,7 @@ func buildTree(def SchemaDefinition, parentId string) TreeNode {
 }
 
 // buildPropertyNode 根据 Property 构建 TreeNode
-func buildPropertyNode(prop Property, parentId string) *TreeNode {
+func (b *treeBuilder) buildPropertyNode(prop Property, parentId string) *TreeNode {
 	label := prop.Name
 	nodeID := prop.Name
 	fullID := parentId + "." + prop.Name
@@ -210,9 +212,9 @@ func buildPropertyNode(prop Property, parentId string) *TreeNode {
 		// fullRef := strings.Join(refParts[1:], ".")
 
 		// 这个可能会导致 循环引用溢出
-		if def, exists := definitionsMap[refName]; exists {
+		if def, exists := b.definitionsMap[refName]; exists {
 			if !slice.Contain(blackList, refName) {
-				childNode := buildTree(def, fullID)
+				childNode := b.buildTree(def, fullID)
 				children = append(children, &childNode)
 			}
 		} else {
@@ -228,7 +230,7 @@ func buildPropertyNode(prop Property, parentId string) *TreeNode {
 	}
 
 	for _, pp := range prop.Value.Properties.AdditionalProperties {
-		children = append(children, buildPropertyNode(pp, fullID))
+		children = append(children, b.buildPropertyNode(pp, fullID))
 	}
 
 	return &TreeNode{
@@ -265,7 +267,9 @@ func printTree(node *TreeNode, level int) {
 }
 
 func InitTrees(schema *openapi_v2.Document) *Docs {
-	definitionsMap = make(map[string]SchemaDefinition)
+	b := &treeBuilder{
+		definitionsMap: make(map[string]SchemaDefinition),
+	}
 
 	// 将 OpenAPI Schema 转换为 JSON 字符串
 	schemaBytes, err := json.Marshal(schema)
@@ -289,28 +293,28 @@ func InitTrees(schema *openapi_v2.Document) *Docs {
 	for _, definition := range definitionList {
 		str := utils.ToJSON(definition)
 		// 解析 Schema 并构建树形结构
-		treeRoot, err := parseOpenAPISchema(str)
+		treeRoot, err := b.parseOpenAPISchema(str)
 		if err != nil {
 			klog.V(2).Infof("Error parsing OpenAPI schema: %v\n", err)
 			continue
 		}
-		trees = append(trees, treeRoot)
+		b.trees = append(b.trees, treeRoot)
 	}
 
 	// 进行遍历处理，将child中ref对应的类型提取出来
 	// 此时应该所有的类型都已经存在了
-	for _, item := range trees {
-		loadChild(&item)
+	for _, item := range b.trees {
+		b.loadChild(&item)
 	}
 
-	for _, item := range trees {
-		loadArrayItems(&item)
+	for _, item := range b.trees {
+		b.loadArrayItems(&item)
 	}
 
 	// 此时 层级结构当中是ref 下面是具体的一个结构体A
 	// 结构体A的child是各个属性
 	// 我们需要把child下的属性上提一级，避免出现A、再展开才是具体属性的情况
-	for _, item := range trees {
+	for _, item := range b.trees {
 		childMoveUpLevel(&item)
 	}
 
@@ -324,26 +328,26 @@ func InitTrees(schema *openapi_v2.Document) *Docs {
 	// }
 
 	// 将所有节点的ID，改为唯一的
-	for _, item := range trees {
+	for _, item := range b.trees {
 		uniqueID(&item)
 	}
 
 	return &Docs{
-		Trees: trees,
+		Trees: b.trees,
 	}
 }
-func loadArrayItems(node *TreeNode) {
+func (b *treeBuilder) loadArrayItems(node *TreeNode) {
 
 	if len(node.Items.Schema) > 0 && node.Items.Schema[0].Ref != "" {
 
 		ref := node.Items.Schema[0].Ref
 		if !slice.Contain(blackList, ref) {
-			refNode := FetchByRef(ref)
+			refNode := b.fetchByRef(ref)
 			node.Children = refNode.Children
 		}
 	}
 	for i := range node.Children {
-		loadArrayItems(node.Children[i])
+		b.loadArrayItems(node.Children[i])
 	}
 }
 func childMoveUpLevel(item *TreeNode) {
@@ -356,15 +360,15 @@ func childMoveUpLevel(item *TreeNode) {
 		childMoveUpLevel(item.Children[i])
 	}
 }
-func loadChild(item *TreeNode) {
+func (b *treeBuilder) loadChild(item *TreeNode) {
 	name := strings.TrimPrefix(item.Ref, "#/definitions/")
 
 	if item.Ref != "" && len(item.Children) > 0 && item.Children[0].ID == name {
-		refNode := FetchByRef(item.Ref)
+		refNode := b.fetchByRef(item.Ref)
 		item.Children[0] = refNode
 	}
 	for i := range item.Children {
-		loadChild(item.Children[i])
+		b.loadChild(item.Children[i])
 	}
 }
 func uniqueID(item *TreeNode) {
@@ -379,7 +383,16 @@ func (d *Docs) ListNames() {
 		klog.Infof("tree info ID: %s\tLabel:%s\t\n Parse GVK=[%s,%s,%s]", tree.ID, tree.Label, tree.group, tree.version, tree.kind)
 	}
 }
-func FetchByRef(ref string) *TreeNode {
+func (b *treeBuilder) fetchByRef(ref string) *TreeNode {
+	return fetchByRef(b.trees, ref)
+}
+
+// FetchByRef 按引用查找文档节点，返回深拷贝
+func (d *Docs) FetchByRef(ref string) *TreeNode {
+	return fetchByRef(d.Trees, ref)
+}
+
+func fetchByRef(trees []TreeNode, ref string) *TreeNode {
 	// #/definitions/io.k8s.api.core.v1.PodSpec
 	klog.V(6).Infof("doc FetchByRef: %s", ref)
 	id := strings.TrimPrefix(ref, "#/definitions/")
@@ -0,0 +1,235 @@
+package kom
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// 默认健康检查间隔
+	defaultHealthCheckInterval = 30 * time.Second
+	// 单次健康检查超时时间
+	healthCheckTimeout = 10 * time.Second
+)
+
+// ClusterState 集群连接状态
+type ClusterState string
+
+const (
+	ClusterStateConnecting   ClusterState = "connecting"   // 注册后尚未完成首次连接
+	ClusterStateConnected    ClusterState = "connected"    // 最近一次访问API Server成功
+	ClusterStateDisconnected ClusterState = "disconnected" // 最近一次访问API Server失败
+)
+
+// ClusterHealth 集群健康状态
+type ClusterHealth struct {
+	State     ClusterState  `json:"state"`               // 连接状态
+	Since     time.Time     `json:"since,omitempty"`     // 进入当前状态的时间
+	LastSeen  time.Time     `json:"lastSeen,omitempty"`  // 最近一次访问API Server成功的时间
+	LastCheck time.Time     `json:"lastCheck,omitempty"` // 最近一次健康检查的时间
+	Latency   time.Duration `json:"latency,omitempty"`   // 最近一次健康检查的耗时
+	Error     string        `json:"error,omitempty"`     // 最近一次失败的错误，连接正常时为空
+}
+
+// StateChangeEvent 集群连接状态变更事件
+type StateChangeEvent struct {
+	ClusterID string       `json:"clusterID"`
+	From      ClusterState `json:"from"`
+	To        ClusterState `json:"to"`
+	Error     error        `json:"-"` // 变为 disconnected 时的错误
+	Time      time.Time    `json:"time"`
+}
+
+// clusterStatus 集群连接状态及最近一次错误
+type clusterStatus struct {
+	mu        sync.RWMutex
+	state     ClusterState
+	err       error
+	since     time.Time // 进入当前状态的时间
+	lastSeen  time.Time // 最近一次访问API Server成功的时间
+	lastCheck time.Time // 最近一次健康检查的时间
+	latency   time.Duration
+}
+
+// healthChecker 定期检查集群健康状态
+type healthChecker struct {
+	stopCh         chan struct{}
+	stopOnce       sync.Once
+	reinitializing atomic.Bool // 是否正在重新获取API资源，同一时间只执行一次
+}
+
+func newHealthChecker() *healthChecker {
+	return &healthChecker{
+		stopCh: make(chan struct{}),
+	}
+}
+
+// stop 停止健康检查
+func (h *healthChecker) stop() {
+	h.stopOnce.Do(func() {
+		close(h.stopCh)
+	})
+}
+
+// State 集群连接状态
+func (ci *ClusterInst) State() ClusterState {
+	ci.status.mu.RLock()
+	defer ci.status.mu.RUnlock()
+	if ci.status.state == "" {
+		return ClusterStateConnecting
+	}
+	return ci.status.state
+}
+
+// LastError 集群最近一次连接失败的错误，连接正常时为nil
+func (ci *ClusterInst) LastError() error {
+	ci.status.mu.RLock()
+	defer ci.status.mu.RUnlock()
+	return ci.status.err
+}
+
+// Health 集群健康状态
+func (ci *ClusterInst) Health() ClusterHealth {
+	ci.status.mu.RLock()
+	defer ci.status.mu.RUnlock()
+	health := ClusterHealth{
+		State:     ci.status.state,
+		Since:     ci.status.since,
+		LastSeen:  ci.status.lastSeen,
+		LastCheck: ci.status.lastCheck,
+		Latency:   ci.status.latency,
+	}
+	if health.State == "" {
+		health.State = ClusterStateConnecting
+	}
+	if ci.status.err != nil {
+		health.Error = ci.status.err.Error()
+	}
+	return health
+}
+
+// setState 更新集群连接状态，状态变化时调用 OnStateChange 注册的回调
+func (ci *ClusterInst) setState(state ClusterState, err error) {
+	ci.status.mu.Lock()
+	from := ci.status.state
+	if from == "" {
+		from = ClusterStateConnecting
+	}
+	now := time.Now()
+	if from != state {
+		ci.status.since = now
+	}
+	if state == ClusterStateConnected {
+		ci.status.lastSeen = now
+	}
+	ci.status.state = state
+	ci.status.err = err
+	ci.status.mu.Unlock()
+
+	if from == state {
+		return
+	}
+	klog.V(2).Infof("cluster %s state %s -> %s", ci.ID, from, state)
+	event := StateChangeEvent{
+		ClusterID: ci.ID,
+		From:      from,
+		To:        state,
+		Error:     err,
+		Time:      now,
+	}
+	c := Clusters()
+	c.mu.RLock()
+	handlers := c.stateChangeHandlers
+	c.mu.RUnlock()
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// OnStateChange 注册集群连接状态变更的回调
+func (c *ClusterInstances) OnStateChange(handler func(event StateChangeEvent)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stateChangeHandlers = append(c.stateChangeHandlers, handler)
+}
+
+// CheckHealth 立即检查集群健康状态
+// 集群从不可用恢复时，重新获取API资源及CRD列表，获取成功后才标记为 connected
+func (ci *ClusterInst) CheckHealth() ClusterHealth {
+	previous := ci.State()
+	start := time.Now()
+	err := ci.probe()
+	latency := time.Since(start)
+
+	ci.status.mu.Lock()
+	ci.status.lastCheck = start
+	ci.status.latency = latency
+	ci.status.mu.Unlock()
+
+	if err != nil {
+		ci.setState(ClusterStateDisconnected, err)
+		return ci.Health()
+	}
+	if previous == ClusterStateConnected && ci.discovery.isLoaded() {
+		ci.setState(ClusterStateConnected, nil)
+		return ci.Health()
+	}
+	// 集群恢复后重新获取，断开期间可能有资源变化
+	// 由 refreshDiscovery 根据结果更新连接状态，上一次尚未完成时不重复执行
+	if ci.health.reinitializing.CompareAndSwap(false, true) {
+		go func() {
+			defer ci.health.reinitializing.Store(false)
+			ci.reinitialize()
+		}()
+	}
+	return ci.Health()
+}
+
+// probe 访问 /readyz 检查集群是否可用，不支持 /readyz 的旧版本集群改为获取版本信息
+func (ci *ClusterInst) probe() error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	err := ci.Client.Discovery().RESTClient().Get().AbsPath("/readyz").Do(ctx).Error()
+	if err != nil && apierrors.IsNotFound(err) {
+		_, err = ci.Client.Discovery().ServerVersion()
+	}
+	return err
+}
+
+// reinitialize 集群恢复后重新获取API资源、CRD列表及版本信息
+func (ci *ClusterInst) reinitialize() {
+	k := ci.Kubectl
+	// 先获取版本信息，标记为 connected 时版本已是最新，集群可能已升级
+	if _, err := ci.reloadServerVersion(); err != nil {
+		klog.V(2).Infof("cluster %s reinitialize server version error: %v", ci.ID, err)
+		return
+	}
+	if err := k.refreshDiscovery(); err != nil {
+		klog.V(2).Infof("cluster %s reinitialize error: %v", ci.ID, err)
+	}
+}
+
+// startHealthCheck 启动后台健康检查
+func (ci *ClusterInst) startHealthCheck(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+	stopCh := ci.health.stopCh
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				ci.CheckHealth()
+			}
+		}
+	}()
+}
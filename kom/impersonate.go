@@ -0,0 +1,219 @@
+package kom
+
+import (
+	"container/list"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/weibaohui/kom/kom/describe"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+// 每个集群最多缓存的身份数量，超出时淘汰最久未使用的
+const defaultImpersonationCacheSize = 64
+
+// impersonatedClients 以某个身份访问集群的客户端
+type impersonatedClients struct {
+	config        *rest.Config
+	client        *kubernetes.Clientset
+	dynamicClient *dynamic.DynamicClient
+	describerMap  lazyValue[map[schema.GroupKind]describe.ResourceDescriber] // 以该身份访问的描述器，首次使用时初始化
+}
+
+// newImpersonatedClients 创建以cfg中身份访问集群的客户端
+func newImpersonatedClients(cfg *rest.Config) (*impersonatedClients, error) {
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &impersonatedClients{
+		config:        cfg,
+		client:        client,
+		dynamicClient: dynamicClient,
+	}, nil
+}
+
+// describers 以该身份访问的描述器
+func (c *impersonatedClients) describers() map[schema.GroupKind]describe.ResourceDescriber {
+	m, _ := c.describerMap.get(func() (map[schema.GroupKind]describe.ResourceDescriber, error) {
+		return describe.InitializeDescriberMap(c.config), nil
+	})
+	return m
+}
+
+// failedRoundTripper 创建模拟身份的客户端失败时使用，每个请求都返回该错误
+// 不能回退到集群自身的凭证，否则会绕过RBAC
+type failedRoundTripper struct {
+	err error
+}
+
+func (f *failedRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, f.err
+}
+
+// failedClients 每个请求都返回err的客户端
+func failedClients(config *rest.Config, err error) *impersonatedClients {
+	cfg := &rest.Config{
+		Host:      config.Host,
+		Transport: &failedRoundTripper{err: err},
+	}
+	// 只设置了Host及Transport，创建客户端不会出错
+	clients, _ := newImpersonatedClients(cfg)
+	return clients
+}
+
+// impersonationEntry 缓存中的一个身份
+type impersonationEntry struct {
+	key     string
+	clients *impersonatedClients
+}
+
+// impersonationCache 按身份缓存的客户端，同一身份复用连接
+// 最多缓存 size 个身份，超出时淘汰最久未使用的
+type impersonationCache struct {
+	mu      sync.Mutex
+	size    int
+	clients map[string]*list.Element
+	order   *list.List // 按最近使用排序，最近使用的在前
+}
+
+func newImpersonationCache() *impersonationCache {
+	return &impersonationCache{
+		size:    defaultImpersonationCacheSize,
+		clients: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get 获取身份对应的客户端，不存在时创建
+func (ic *impersonationCache) get(config *rest.Config, impersonate *rest.ImpersonationConfig) (*impersonatedClients, error) {
+	key := impersonationKey(impersonate)
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	if e, ok := ic.clients[key]; ok {
+		ic.order.MoveToFront(e)
+		return e.Value.(*impersonationEntry).clients, nil
+	}
+	cfg := rest.CopyConfig(config)
+	cfg.Impersonate = *impersonate
+	clients, err := newImpersonatedClients(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ic.clients[key] = ic.order.PushFront(&impersonationEntry{key: key, clients: clients})
+	for ic.order.Len() > ic.size {
+		oldest := ic.order.Back()
+		ic.order.Remove(oldest)
+		delete(ic.clients, oldest.Value.(*impersonationEntry).key)
+	}
+	return clients, nil
+}
+
+// impersonationKey 身份的唯一标识，用于缓存客户端以及查询缓存的key
+func impersonationKey(impersonate *rest.ImpersonationConfig) string {
+	if impersonate == nil {
+		return ""
+	}
+	groups := append([]string{}, impersonate.Groups...)
+	sort.Strings(groups)
+	extraKeys := make([]string, 0, len(impersonate.Extra))
+	for k := range impersonate.Extra {
+		extraKeys = append(extraKeys, k)
+	}
+	sort.Strings(extraKeys)
+	var extras []string
+	for _, k := range extraKeys {
+		values := append([]string{}, impersonate.Extra[k]...)
+		sort.Strings(values)
+		extras = append(extras, fmt.Sprintf("%s=%s", k, strings.Join(values, ",")))
+	}
+	return fmt.Sprintf("user=%s;uid=%s;groups=%s;extra=%s",
+		impersonate.UserName, impersonate.UID, strings.Join(groups, ","), strings.Join(extras, ";"))
+}
+
+// As 以指定用户及用户组的身份执行操作，API Server 按该用户的RBAC权限鉴权
+// 需要当前集群的凭证具有 impersonate 权限
+func (k *Kubectl) As(user string, groups ...string) *Kubectl {
+	tx := k.getInstance()
+	impersonate := tx.Statement.impersonation()
+	impersonate.UserName = user
+	impersonate.Groups = groups
+	tx.Statement.Impersonate = impersonate
+	return tx
+}
+
+// AsUID 设置模拟身份的UID
+func (k *Kubectl) AsUID(uid string) *Kubectl {
+	tx := k.getInstance()
+	impersonate := tx.Statement.impersonation()
+	impersonate.UID = uid
+	tx.Statement.Impersonate = impersonate
+	return tx
+}
+
+// AsExtra 设置模拟身份的附加信息，如 scopes
+func (k *Kubectl) AsExtra(key string, values ...string) *Kubectl {
+	tx := k.getInstance()
+	impersonate := tx.Statement.impersonation()
+	extra := make(map[string][]string, len(impersonate.Extra)+1)
+	for k, v := range impersonate.Extra {
+		extra[k] = v
+	}
+	extra[key] = values
+	impersonate.Extra = extra
+	tx.Statement.Impersonate = impersonate
+	return tx
+}
+
+// impersonation 复制当前的模拟身份，避免修改其他实例共享的配置
+func (s *Statement) impersonation() *rest.ImpersonationConfig {
+	if s.Impersonate == nil {
+		return &rest.ImpersonationConfig{}
+	}
+	impersonate := *s.Impersonate
+	return &impersonate
+}
+
+// IdentityKey 模拟身份的唯一标识，未设置时为空
+func (s *Statement) IdentityKey() string {
+	return impersonationKey(s.Impersonate)
+}
+
+// impersonationError 创建模拟身份客户端的错误，未设置模拟身份或创建成功时返回nil
+func (k *Kubectl) impersonationError() error {
+	if k.Statement == nil || k.Statement.Impersonate == nil {
+		return nil
+	}
+	cluster := k.parentCluster()
+	if _, err := cluster.impersonation.get(cluster.Config, k.Statement.Impersonate); err != nil {
+		return fmt.Errorf("create impersonated client for cluster %s error: %v", k.ID, err)
+	}
+	return nil
+}
+
+// impersonatedClients 当前模拟身份的客户端，未设置模拟身份时返回nil
+// 创建失败时返回的客户端每个请求都返回该错误，不会以集群自身的凭证访问
+func (k *Kubectl) impersonatedClients() *impersonatedClients {
+	if k.Statement == nil || k.Statement.Impersonate == nil {
+		return nil
+	}
+	cluster := k.parentCluster()
+	clients, err := cluster.impersonation.get(cluster.Config, k.Statement.Impersonate)
+	if err != nil {
+		err = fmt.Errorf("create impersonated client for cluster %s error: %v", k.ID, err)
+		klog.V(2).Infof("%v", err)
+		return failedClients(cluster.Config, err)
+	}
+	return clients
+}
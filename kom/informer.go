@@ -0,0 +1,329 @@
+package kom
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// 默认informer空闲超时时间，超过该时间未被使用的informer会被停止
+	defaultInformerIdleTimeout = 10 * time.Minute
+	// informer首次同步的超时时间
+	informerSyncTimeout = 30 * time.Second
+	// informer同步失败后的重试间隔，连续失败时翻倍，最长为 informerRetryMaxInterval
+	informerRetryInterval    = 2 * time.Second
+	informerRetryMaxInterval = time.Minute
+)
+
+// informerManager 集群级别的informer管理器
+// 每个GVR对应一个全命名空间的informer，首次使用时启动，空闲超时后停止
+type informerManager struct {
+	kubectl     *Kubectl
+	idleTimeout time.Duration
+	mu          sync.Mutex
+	informers   map[schema.GroupVersionResource]*gvrInformer
+	failures    map[schema.GroupVersionResource]*informerFailure // 同步失败的GVR，重试间隔内直接返回错误
+	janitor     bool                                             // 清理协程是否已启动
+}
+
+// gvrInformer 单个GVR的informer
+type gvrInformer struct {
+	informer informers.GenericInformer
+	stopCh   chan struct{}
+	lastUsed time.Time
+
+	failOnce sync.Once
+	failedCh chan struct{} // 首次同步前获取列表失败时关闭
+	failErr  error         // 首次同步前获取列表的错误，failedCh 关闭后可读
+}
+
+// informerFailure GVR同步失败的记录
+type informerFailure struct {
+	failures int
+	failedAt time.Time
+	err      error
+}
+
+// recent 仍在重试间隔内
+func (f *informerFailure) recent() bool {
+	interval := informerRetryInterval
+	for i := 1; i < f.failures && interval < informerRetryMaxInterval; i++ {
+		interval *= 2
+	}
+	return time.Since(f.failedAt) < min(interval, informerRetryMaxInterval)
+}
+
+func newInformerManager(k *Kubectl) *informerManager {
+	return &informerManager{
+		kubectl:     k,
+		idleTimeout: defaultInformerIdleTimeout,
+		informers:   make(map[schema.GroupVersionResource]*gvrInformer),
+		failures:    make(map[schema.GroupVersionResource]*informerFailure),
+	}
+}
+
+// SetIdleTimeout 设置informer空闲超时时间
+func (m *informerManager) SetIdleTimeout(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if d > 0 {
+		m.idleTimeout = d
+	}
+}
+
+// ActiveGVRs 返回当前正在运行的informer对应的GVR列表
+func (m *informerManager) ActiveGVRs() []schema.GroupVersionResource {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []schema.GroupVersionResource
+	for gvr := range m.informers {
+		list = append(list, gvr)
+	}
+	return list
+}
+
+// Stop 停止指定GVR的informer
+func (m *informerManager) Stop(gvr schema.GroupVersionResource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if gi, ok := m.informers[gvr]; ok {
+		close(gi.stopCh)
+		delete(m.informers, gvr)
+	}
+	delete(m.failures, gvr)
+}
+
+// StopAll 停止全部informer
+func (m *informerManager) StopAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for gvr, gi := range m.informers {
+		close(gi.stopCh)
+		delete(m.informers, gvr)
+	}
+	clear(m.failures)
+}
+
+// getOrStart 获取GVR对应的informer，不存在则启动，并等待首次同步完成
+func (m *informerManager) getOrStart(ctx context.Context, gvr schema.GroupVersionResource) (informers.GenericInformer, error) {
+	m.mu.Lock()
+	gi, ok := m.informers[gvr]
+	if !ok {
+		if f := m.failures[gvr]; f != nil && f.recent() {
+			m.mu.Unlock()
+			return nil, f.err
+		}
+		informer := dynamicinformer.NewFilteredDynamicInformer(m.kubectl.DynamicClient(), gvr, metav1.NamespaceAll, 0,
+			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, nil)
+		gi = &gvrInformer{
+			informer: informer,
+			stopCh:   make(chan struct{}),
+			failedCh: make(chan struct{}),
+		}
+		// 无权限、资源不存在等错误不会自行恢复，首次同步前出错即视为失败，不等待超时
+		_ = informer.Informer().SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+			if informer.Informer().HasSynced() {
+				cache.DefaultWatchErrorHandler(r, err)
+				return
+			}
+			gi.failOnce.Do(func() {
+				gi.failErr = err
+				close(gi.failedCh)
+			})
+		})
+		m.informers[gvr] = gi
+		go informer.Informer().Run(gi.stopCh)
+		klog.V(4).Infof("informer for %s started", gvr.String())
+		if !m.janitor {
+			m.janitor = true
+			go m.cleanup()
+		}
+	}
+	gi.lastUsed = time.Now()
+	stopCh := gi.stopCh
+	m.mu.Unlock()
+
+	if gi.informer.Informer().HasSynced() {
+		return gi.informer, nil
+	}
+
+	// 等待同步，调用方ctx取消、超时、获取列表失败或informer被停止时返回
+	syncCtx, cancel := context.WithTimeout(ctx, informerSyncTimeout)
+	defer cancel()
+	waitCh := make(chan struct{})
+	go func() {
+		select {
+		case <-syncCtx.Done():
+		case <-stopCh:
+		case <-gi.failedCh:
+		}
+		close(waitCh)
+	}()
+	if !cache.WaitForCacheSync(waitCh, gi.informer.Informer().HasSynced) {
+		err := fmt.Errorf("informer %s 同步失败", gvr.String())
+		select {
+		case <-gi.failedCh:
+			err = fmt.Errorf("informer %s 同步失败: %v", gvr.String(), gi.failErr)
+		default:
+		}
+		// 调用方取消时informer可能仍能正常同步，保留给后续使用
+		if ctx.Err() == nil {
+			m.fail(gvr, gi, err)
+		}
+		return nil, err
+	}
+	m.mu.Lock()
+	delete(m.failures, gvr)
+	m.mu.Unlock()
+	return gi.informer, nil
+}
+
+// fail 同步失败时停止informer并记录失败，重试间隔内的查询直接返回错误，不再等待同步超时
+func (m *informerManager) fail(gvr schema.GroupVersionResource, gi *gvrInformer, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// 已被其他等待者或清理协程停止，同一次失败只记录一次
+	if m.informers[gvr] != gi {
+		return
+	}
+	close(gi.stopCh)
+	delete(m.informers, gvr)
+	f := m.failures[gvr]
+	if f == nil {
+		f = &informerFailure{}
+		m.failures[gvr] = f
+	}
+	f.failures++
+	f.failedAt = time.Now()
+	f.err = err
+	klog.V(2).Infof("informer for %s stopped: %v", gvr.String(), err)
+}
+
+// cleanup 定期停止空闲的informer，没有informer时退出
+func (m *informerManager) cleanup() {
+	for {
+		m.mu.Lock()
+		interval := m.idleTimeout / 2
+		m.mu.Unlock()
+		time.Sleep(interval)
+
+		m.mu.Lock()
+		for gvr, gi := range m.informers {
+			if time.Since(gi.lastUsed) > m.idleTimeout {
+				close(gi.stopCh)
+				delete(m.informers, gvr)
+				klog.V(4).Infof("informer for %s stopped after idle %s", gvr.String(), m.idleTimeout)
+			}
+		}
+		if len(m.informers) == 0 {
+			m.janitor = false
+			m.mu.Unlock()
+			return
+		}
+		m.mu.Unlock()
+	}
+}
+
+// Get 从informer缓存中获取单个资源，返回对象的副本
+func (m *informerManager) Get(ctx context.Context, gvr schema.GroupVersionResource, ns string, name string) (*unstructured.Unstructured, error) {
+	informer, err := m.getOrStart(ctx, gvr)
+	if err != nil {
+		return nil, err
+	}
+	lister := informer.Lister()
+	var obj interface{}
+	if ns != "" {
+		obj, err = lister.ByNamespace(ns).Get(name)
+	} else {
+		obj, err = lister.Get(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	item, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("informer缓存对象类型错误 %T", obj)
+	}
+	return item.DeepCopy(), nil
+}
+
+// List 从informer缓存中获取资源列表
+// ns 为空时返回所有命名空间的资源，支持ListOptions中的LabelSelector，
+// FieldSelector 仅支持 metadata.name、metadata.namespace
+// 返回的Items与informer缓存共享数据，调用方修改前需要DeepCopy
+func (m *informerManager) List(ctx context.Context, gvr schema.GroupVersionResource, ns string, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	labelSelector := labels.Everything()
+	if opts.LabelSelector != "" {
+		s, err := labels.Parse(opts.LabelSelector)
+		if err != nil {
+			return nil, fmt.Errorf("LabelSelector %s 解析失败: %v", opts.LabelSelector, err)
+		}
+		labelSelector = s
+	}
+	fieldSelector := fields.Everything()
+	if opts.FieldSelector != "" {
+		s, err := fields.ParseSelector(opts.FieldSelector)
+		if err != nil {
+			return nil, fmt.Errorf("FieldSelector %s 解析失败: %v", opts.FieldSelector, err)
+		}
+		for _, r := range s.Requirements() {
+			if r.Field != "metadata.name" && r.Field != "metadata.namespace" {
+				return nil, fmt.Errorf("缓存查询不支持FieldSelector字段 %s", r.Field)
+			}
+		}
+		fieldSelector = s
+	}
+
+	informer, err := m.getOrStart(ctx, gvr)
+	if err != nil {
+		return nil, err
+	}
+
+	lister := informer.Lister()
+	var objs []interface{}
+	if ns != "" {
+		list, err := lister.ByNamespace(ns).List(labelSelector)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range list {
+			objs = append(objs, o)
+		}
+	} else {
+		list, err := lister.List(labelSelector)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range list {
+			objs = append(objs, o)
+		}
+	}
+
+	result := &unstructured.UnstructuredList{Items: []unstructured.Unstructured{}}
+	for _, o := range objs {
+		item, ok := o.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		if !fieldSelector.Matches(fields.Set{
+			"metadata.name":      item.GetName(),
+			"metadata.namespace": item.GetNamespace(),
+		}) {
+			continue
+		}
+		result.Items = append(result.Items, *item)
+	}
+	return result, nil
+}
@@ -15,14 +15,15 @@ type Kubectl struct {
 	Statement *Statement // statement
 	Error     error      // 存放ERROR信息
 
-	clone int
+	clone   int
+	cluster *ClusterInst // 所属集群，避免每次调用都按ID查找
 }
 
 // 初始化 kubectl
-func initKubectl(config *rest.Config, id string) *Kubectl {
+func initKubectl(config *rest.Config, id string, cluster *ClusterInst) *Kubectl {
 	klog.V(2).Infof("k8s init 服务器地址：%s\n", config.Host)
 
-	k := &Kubectl{ID: id, clone: 1}
+	k := &Kubectl{ID: id, clone: 1, cluster: cluster}
 
 	k.Statement = &Statement{
 		Context: context.Background(),
@@ -32,13 +33,15 @@ func initKubectl(config *rest.Config, id string) *Kubectl {
 	return k
 }
 
-// 获取一个全新的实例，只保留ctx
+// 获取一个全新的实例，只保留ctx、模拟身份以及试运行标志
 func (k *Kubectl) newInstance() *Kubectl {
-	tx := &Kubectl{ID: k.ID, Error: k.Error}
+	tx := &Kubectl{ID: k.ID, Error: k.Error, cluster: k.cluster}
 	// clone with new statement
 	tx.Statement = &Statement{
-		Kubectl: k.Statement.Kubectl,
-		Context: k.Statement.Context,
+		Kubectl:     k.Statement.Kubectl,
+		Context:     k.Statement.Context,
+		Impersonate: k.Statement.Impersonate, // 与ctx一样，后续操作仍以该身份执行
+		DryRun:      k.Statement.DryRun,      // 后续的变更操作同样为试运行
 	}
 	return tx
 
@@ -47,48 +50,86 @@ func (k *Kubectl) newInstance() *Kubectl {
 func (k *Kubectl) getInstance() *Kubectl {
 
 	if k.clone > 0 {
-		tx := &Kubectl{ID: k.ID, Error: k.Error}
-		// clone with new statement
-		tx.Statement = &Statement{
-			Kubectl:      k.Statement.Kubectl,
-			Context:      k.Statement.Context,
-			ListOptions:  k.Statement.ListOptions,
-			AllNamespace: k.Statement.AllNamespace,
-			Namespace:    k.Statement.Namespace,
-			Namespaced:   k.Statement.Namespaced,
-			GVR:          k.Statement.GVR,
-			GVK:          k.Statement.GVK,
-			Name:         k.Statement.Name,
-			CacheTTL:     k.Statement.CacheTTL,
-			Filter:       k.Statement.Filter,
-			ForceDelete:  k.Statement.ForceDelete,
-		}
-		return tx
+		return k.copyInstance()
 	}
 
 	return k
 }
+
+// copyInstance 复制一个新实例，保留全部查询条件，修改新实例不影响当前实例
+func (k *Kubectl) copyInstance() *Kubectl {
+	tx := &Kubectl{ID: k.ID, Error: k.Error, cluster: k.cluster}
+	// clone with new statement
+	tx.Statement = &Statement{
+		Kubectl:        k.Statement.Kubectl,
+		Context:        k.Statement.Context,
+		ListOptions:    k.Statement.ListOptions,
+		AllNamespace:   k.Statement.AllNamespace,
+		Namespace:      k.Statement.Namespace,
+		Namespaced:     k.Statement.Namespaced,
+		GVR:            k.Statement.GVR,
+		GVK:            k.Statement.GVK,
+		Name:           k.Statement.Name,
+		CacheTTL:       k.Statement.CacheTTL,
+		Filter:         k.Statement.Filter,
+		ForceDelete:    k.Statement.ForceDelete,
+		FromCache:      k.Statement.FromCache,
+		Impersonate:    k.Statement.Impersonate,
+		BatchSize:      k.Statement.BatchSize,
+		DryRun:         k.Statement.DryRun,
+		RetryPolicy:    k.Statement.RetryPolicy,
+		FieldManager:   k.Statement.FieldManager,
+		ForceConflicts: k.Statement.ForceConflicts,
+	}
+	return tx
+}
 func (k *Kubectl) Callback() *callbacks {
-	cluster := Clusters().GetClusterById(k.ID)
+	cluster := k.parentCluster()
 	return cluster.callbacks
 }
+
+// RestConfig 集群配置，设置了模拟用户身份时返回带有身份的配置
 func (k *Kubectl) RestConfig() *rest.Config {
-	cluster := Clusters().GetClusterById(k.ID)
+	if clients := k.impersonatedClients(); clients != nil {
+		return clients.config
+	}
+	cluster := k.parentCluster()
 	return cluster.Config
 }
+
+// Client 集群客户端，设置了模拟用户身份时返回以该身份访问的客户端
 func (k *Kubectl) Client() *kubernetes.Clientset {
-	cluster := Clusters().GetClusterById(k.ID)
+	if clients := k.impersonatedClients(); clients != nil {
+		return clients.client
+	}
+	cluster := k.parentCluster()
 	return cluster.Client
 }
 func (k *Kubectl) ClusterCache() *ristretto.Cache[string, any] {
-	cache := Clusters().GetClusterById(k.ID).Cache
+	cache := k.parentCluster().Cache
 	return cache
 }
+
+// DynamicClient 集群动态客户端，设置了模拟用户身份时返回以该身份访问的客户端
 func (k *Kubectl) DynamicClient() *dynamic.DynamicClient {
-	cluster := Clusters().GetClusterById(k.ID)
+	if clients := k.impersonatedClients(); clients != nil {
+		return clients.dynamicClient
+	}
+	cluster := k.parentCluster()
 	return cluster.DynamicClient
 }
+
+// Informers 集群informer缓存管理器
+func (k *Kubectl) Informers() *informerManager {
+	return k.parentCluster().informers
+}
+
+// parentCluster 所属集群，优先使用创建时绑定的集群实例
+// 注册过程中集群尚未加入管理器，也能正常访问
 func (k *Kubectl) parentCluster() *ClusterInst {
+	if k.cluster != nil {
+		return k.cluster
+	}
 	cluster := Clusters().GetClusterById(k.ID)
 	return cluster
 }
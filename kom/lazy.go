@@ -0,0 +1,71 @@
+package kom
+
+import (
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+// lazyValue 延迟加载的值
+// 首次使用时加载，加载成功后不再重复加载；加载失败时下次使用会重试
+type lazyValue[T any] struct {
+	mu     sync.Mutex
+	loaded bool
+	value  T
+}
+
+// get 获取值，未加载时调用load加载，并发调用时只有一个会执行load
+func (l *lazyValue[T]) get(load func() (T, error)) (T, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.loaded {
+		return l.value, nil
+	}
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	l.value = value
+	l.loaded = true
+	return l.value, nil
+}
+
+// reload 重新加载，成功后替换已加载的值，失败时保留之前的值
+func (l *lazyValue[T]) reload(load func() (T, error)) (T, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	l.value = value
+	l.loaded = true
+	return l.value, nil
+}
+
+// peek 获取已加载的值，不触发加载
+func (l *lazyValue[T]) peek() (T, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.value, l.loaded
+}
+
+// warmUp 后台预热，注册后在后台连接集群，不阻塞注册
+// 连接失败时集群状态为 disconnected，使用时会再次尝试
+func (ci *ClusterInst) warmUp() {
+	k := ci.Kubectl
+	if err := k.ensureDiscovery(); err != nil {
+		klog.V(2).Infof("cluster %s warm up error: %v", ci.ID, err)
+	} else {
+		_, _ = ci.loadServerVersion()
+		if ci.Options.EagerLoadDocs {
+			ci.loadDocs()
+		}
+		if ci.Options.EagerLoadDescribers {
+			ci.loadDescriberMap()
+		}
+	}
+	if !ci.Options.DisableDiscoveryWatch {
+		k.startDiscoveryWatch(ci.Options.DiscoveryRefreshInterval) // 后台刷新API资源及CRD列表，连接失败后也会借此恢复
+	}
+}
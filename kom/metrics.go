@@ -0,0 +1,257 @@
+package kom
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// 默认的耗时分布区间，单位秒，与Prometheus客户端默认值一致
+var defaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// 操作结果
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+var metricsRegistry = newMetricsRegistry()
+
+// MetricsRegistry 操作指标，按集群、GVR、操作、结果统计次数及耗时分布
+// 实现了 http.Handler，以Prometheus文本格式输出，无需依赖Prometheus客户端库
+type MetricsRegistry struct {
+	mu         sync.RWMutex
+	buckets    []float64
+	operations map[operationKey]*operationMetric
+}
+
+type operationKey struct {
+	cluster   string
+	gvr       string
+	operation string
+	outcome   string
+}
+
+type operationMetric struct {
+	count   uint64
+	sum     float64
+	buckets []uint64 // 与 MetricsRegistry.buckets 对应，非累计值
+}
+
+// OperationMetric 单个维度的操作统计
+type OperationMetric struct {
+	Cluster   string        `json:"cluster"`
+	GVR       string        `json:"gvr"`
+	Operation string        `json:"operation"`
+	Outcome   string        `json:"outcome"`
+	Count     uint64        `json:"count"`
+	Duration  time.Duration `json:"duration"` // 累计耗时
+}
+
+func newMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{
+		buckets:    defaultDurationBuckets,
+		operations: make(map[operationKey]*operationMetric),
+	}
+}
+
+// Metrics 全局操作指标
+func Metrics() *MetricsRegistry {
+	return metricsRegistry
+}
+
+// observe 记录一次操作
+func (m *MetricsRegistry) observe(cluster string, gvr schema.GroupVersionResource, operation string, err error, duration time.Duration) {
+	key := operationKey{
+		cluster:   cluster,
+		gvr:       gvrLabel(gvr),
+		operation: operation,
+		outcome:   OutcomeSuccess,
+	}
+	if err != nil {
+		key.outcome = OutcomeError
+	}
+	seconds := duration.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	metric, ok := m.operations[key]
+	if !ok {
+		metric = &operationMetric{buckets: make([]uint64, len(m.buckets))}
+		m.operations[key] = metric
+	}
+	metric.count++
+	metric.sum += seconds
+	for i, upper := range m.buckets {
+		if seconds <= upper {
+			metric.buckets[i]++
+			break
+		}
+	}
+}
+
+// Operations 当前的操作统计，按集群、GVR、操作、结果排序
+func (m *MetricsRegistry) Operations() []OperationMetric {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make([]OperationMetric, 0, len(m.operations))
+	for _, key := range m.sortedKeys() {
+		metric := m.operations[key]
+		result = append(result, OperationMetric{
+			Cluster:   key.cluster,
+			GVR:       key.gvr,
+			Operation: key.operation,
+			Outcome:   key.outcome,
+			Count:     metric.count,
+			Duration:  time.Duration(metric.sum * float64(time.Second)),
+		})
+	}
+	return result
+}
+
+// Reset 清空操作统计
+func (m *MetricsRegistry) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.operations = make(map[operationKey]*operationMetric)
+}
+
+// ServeHTTP 以Prometheus文本格式输出指标，如 http.Handle("/metrics", kom.Metrics())
+func (m *MetricsRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := m.Write(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Write 以Prometheus文本格式写入全部指标，包括操作统计以及各集群的查询缓存命中率
+func (m *MetricsRegistry) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	m.writeOperations(bw)
+	writeCacheMetrics(bw)
+	return bw.Flush()
+}
+
+func (m *MetricsRegistry) writeOperations(w io.Writer) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := m.sortedKeys()
+
+	fmt.Fprintln(w, "# HELP kom_operations_total Total number of kom operations.")
+	fmt.Fprintln(w, "# TYPE kom_operations_total counter")
+	for _, key := range keys {
+		fmt.Fprintf(w, "kom_operations_total{%s} %d\n", key.labels(), m.operations[key].count)
+	}
+
+	fmt.Fprintln(w, "# HELP kom_operation_duration_seconds Duration of kom operations in seconds.")
+	fmt.Fprintln(w, "# TYPE kom_operation_duration_seconds histogram")
+	for _, key := range keys {
+		metric := m.operations[key]
+		labels := key.labels()
+		var cumulative uint64
+		for i, upper := range m.buckets {
+			cumulative += metric.buckets[i]
+			fmt.Fprintf(w, "kom_operation_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, formatFloat(upper), cumulative)
+		}
+		fmt.Fprintf(w, "kom_operation_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, metric.count)
+		fmt.Fprintf(w, "kom_operation_duration_seconds_sum{%s} %s\n", labels, formatFloat(metric.sum))
+		fmt.Fprintf(w, "kom_operation_duration_seconds_count{%s} %d\n", labels, metric.count)
+	}
+}
+
+// writeCacheMetrics 各集群查询缓存的命中统计，禁用缓存的集群不输出
+// 使用全局共享缓存的集群，统计值为共享缓存的整体数据
+func writeCacheMetrics(w io.Writer) {
+	clusters := Clusters().AllClusters()
+	ids := make([]string, 0, len(clusters))
+	for id := range clusters {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	type cacheMetric struct {
+		labels string
+		stats  *CacheStats
+	}
+	var items []cacheMetric
+	for _, id := range ids {
+		stats := clusters[id].Kubectl.Status().CacheStats()
+		if stats.Disabled {
+			continue
+		}
+		labels := fmt.Sprintf("cluster=\"%s\",shared=\"%t\"", escapeLabel(id), stats.Shared)
+		items = append(items, cacheMetric{labels: labels, stats: stats})
+	}
+
+	fmt.Fprintln(w, "# HELP kom_cache_hits_total Total number of query cache hits.")
+	fmt.Fprintln(w, "# TYPE kom_cache_hits_total counter")
+	for _, item := range items {
+		fmt.Fprintf(w, "kom_cache_hits_total{%s} %d\n", item.labels, item.stats.Hits)
+	}
+	fmt.Fprintln(w, "# HELP kom_cache_misses_total Total number of query cache misses.")
+	fmt.Fprintln(w, "# TYPE kom_cache_misses_total counter")
+	for _, item := range items {
+		fmt.Fprintf(w, "kom_cache_misses_total{%s} %d\n", item.labels, item.stats.Misses)
+	}
+	fmt.Fprintln(w, "# HELP kom_cache_hit_ratio Ratio of query cache hits to total lookups.")
+	fmt.Fprintln(w, "# TYPE kom_cache_hit_ratio gauge")
+	for _, item := range items {
+		fmt.Fprintf(w, "kom_cache_hit_ratio{%s} %s\n", item.labels, formatFloat(item.stats.HitRatio))
+	}
+}
+
+func (m *MetricsRegistry) sortedKeys() []operationKey {
+	keys := make([]operationKey, 0, len(m.operations))
+	for key := range m.operations {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.cluster != b.cluster {
+			return a.cluster < b.cluster
+		}
+		if a.gvr != b.gvr {
+			return a.gvr < b.gvr
+		}
+		if a.operation != b.operation {
+			return a.operation < b.operation
+		}
+		return a.outcome < b.outcome
+	})
+	return keys
+}
+
+func (k operationKey) labels() string {
+	return fmt.Sprintf("cluster=\"%s\",gvr=\"%s\",operation=\"%s\",outcome=\"%s\"",
+		escapeLabel(k.cluster), escapeLabel(k.gvr), escapeLabel(k.operation), k.outcome)
+}
+
+// gvrLabel GVR标签值，如 apps/v1/deployments，core组为 v1/pods
+func gvrLabel(gvr schema.GroupVersionResource) string {
+	if gvr.Empty() {
+		return ""
+	}
+	if gvr.Group == "" {
+		return gvr.Version + "/" + gvr.Resource
+	}
+	return gvr.Group + "/" + gvr.Version + "/" + gvr.Resource
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel 按Prometheus文本格式转义标签值
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,107 @@
+package kom
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// 通过kubeconfig文件注册集群时默认的QPS及Burst
+	defaultPathQPS   = 200
+	defaultPathBurst = 2000
+)
+
+// RegisterOptions 注册集群时的可选参数
+type RegisterOptions struct {
+	Tags                     map[string]string `json:"tags,omitempty"`                     // 集群标签，如 env=prod，可通过 Clusters().Select 按标签选择集群
+	QPS                      float32           `json:"qps,omitempty"`                      // 每秒请求数，为0时使用config中的值，通过kubeconfig文件注册时默认200
+	Burst                    int               `json:"burst,omitempty"`                    // 突发请求数，为0时使用config中的值，通过kubeconfig文件注册时默认2000
+	Timeout                  time.Duration     `json:"timeout,omitempty"`                  // 单次请求超时时间
+	UserAgent                string            `json:"userAgent,omitempty"`                // 请求的User-Agent
+	TLS                      TLSOptions        `json:"tls,omitempty"`                      // TLS配置，覆盖config中的值
+	ProxyURL                 string            `json:"proxyURL,omitempty"`                 // 代理地址，如 http://127.0.0.1:8080、socks5://127.0.0.1:1080
+	Cache                    CacheOptions      `json:"cache,omitempty"`                    // 查询缓存配置
+	DiscoveryRefreshInterval time.Duration     `json:"discoveryRefreshInterval,omitempty"` // 定期刷新API资源的间隔，默认5分钟
+	DisableDiscoveryWatch    bool              `json:"disableDiscoveryWatch,omitempty"`    // 禁用后台Watch CRD及定期刷新API资源
+	HealthCheckInterval      time.Duration     `json:"healthCheckInterval,omitempty"`      // 健康检查间隔，默认30秒
+	DisableHealthCheck       bool              `json:"disableHealthCheck,omitempty"`       // 禁用后台健康检查
+	EagerLoadDocs            bool              `json:"eagerLoadDocs,omitempty"`            // 注册后在后台加载OpenAPI文档，默认首次使用时加载
+	EagerLoadDescribers      bool              `json:"eagerLoadDescribers,omitempty"`      // 注册后在后台初始化Describe描述器，默认首次使用时初始化
+	Retry                    *RetryPolicy      `json:"retry,omitempty"`                    // 失败重试策略，为nil时不重试，可通过 WithRetry 为单次操作覆盖
+}
+
+// TLSOptions 集群TLS配置
+type TLSOptions struct {
+	Insecure   bool   `json:"insecure,omitempty"`   // 跳过服务端证书校验
+	CAData     []byte `json:"caData,omitempty"`     // 服务端CA证书，PEM格式
+	ServerName string `json:"serverName,omitempty"` // 校验证书时使用的服务端名称
+}
+
+// CacheOptions 集群查询缓存配置
+type CacheOptions struct {
+	Disabled    bool  `json:"disabled,omitempty"`    // 禁用查询缓存，WithCache 将直接查询API Server
+	MaxCost     int64 `json:"maxCost,omitempty"`     // 缓存最大占用，单位字节，默认 256MB
+	NumCounters int64 `json:"numCounters,omitempty"` // 访问频率计数器数量，建议为预计缓存条目数的10倍，默认 1e6
+	Dedicated   bool  `json:"dedicated,omitempty"`   // 设置了全局共享缓存时，仍使用独立缓存
+}
+
+// getRegisterOptions 获取可变参数中的注册参数，未传入时返回默认值
+func getRegisterOptions(opts []RegisterOptions) RegisterOptions {
+	if len(opts) > 0 {
+		return opts[0]
+	}
+	return RegisterOptions{}
+}
+
+// applyToConfig 将注册参数应用到rest config
+func (o RegisterOptions) applyToConfig(config *rest.Config) error {
+	if o.QPS > 0 {
+		config.QPS = o.QPS
+	}
+	if o.Burst > 0 {
+		config.Burst = o.Burst
+	}
+	if o.Timeout > 0 {
+		config.Timeout = o.Timeout
+	}
+	if o.UserAgent != "" {
+		config.UserAgent = o.UserAgent
+	}
+	if o.TLS.ServerName != "" {
+		config.TLSClientConfig.ServerName = o.TLS.ServerName
+	}
+	if len(o.TLS.CAData) > 0 {
+		config.TLSClientConfig.CAData = o.TLS.CAData
+		config.TLSClientConfig.CAFile = ""
+	}
+	if o.TLS.Insecure {
+		// client-go 不允许同时设置CA与Insecure
+		config.TLSClientConfig.Insecure = true
+		config.TLSClientConfig.CAData = nil
+		config.TLSClientConfig.CAFile = ""
+	}
+	if o.ProxyURL != "" {
+		proxyURL, err := url.Parse(o.ProxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy url %s: %v", o.ProxyURL, err)
+		}
+		config.Proxy = http.ProxyURL(proxyURL)
+	}
+	return nil
+}
+
+// withPathDefaults 通过kubeconfig文件注册时，未设置QPS及Burst则使用较大的默认值
+func withPathDefaults(opts []RegisterOptions) RegisterOptions {
+	options := getRegisterOptions(opts)
+	if options.QPS <= 0 {
+		options.QPS = defaultPathQPS
+	}
+	if options.Burst <= 0 {
+		options.Burst = defaultPathBurst
+	}
+	return options
+}
@@ -0,0 +1,281 @@
+package kom
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	defaultRetryInitialInterval = 100 * time.Millisecond
+	defaultRetryMaxInterval     = 10 * time.Second
+	defaultRetryMultiplier      = 2
+	defaultRetryJitter          = 0.2
+	// GetAndUpdate 未设置重试策略时，冲突后的最大重试次数
+	defaultConflictRetries = 5
+)
+
+// 支持自动重试的操作，watch、exec、logs等流式操作不重试
+// create 只在指定了名称时重试，见 retryAllowed
+var retryableOperations = map[string]bool{
+	"get":      true,
+	"list":     true,
+	"describe": true,
+	"create":   true,
+	"update":   true,
+	"patch":    true,
+	"delete":   true,
+}
+
+// RetryPolicy 失败重试策略，按指数退避并叠加随机抖动，服务端返回 Retry-After 时以其为准
+// 可在注册集群时通过 RegisterOptions.Retry 设置，也可通过 WithRetry 为单次操作设置
+type RetryPolicy struct {
+	MaxRetries      int                  `json:"maxRetries,omitempty"`      // 最大重试次数，为0时不重试
+	InitialInterval time.Duration        `json:"initialInterval,omitempty"` // 首次重试的等待时间，默认100毫秒
+	MaxInterval     time.Duration        `json:"maxInterval,omitempty"`     // 最长等待时间，默认10秒
+	Multiplier      float64              `json:"multiplier,omitempty"`      // 每次重试等待时间的倍数，默认2
+	Jitter          float64              `json:"jitter,omitempty"`          // 随机抖动比例，0~1，默认0.2
+	Retryable       func(err error) bool `json:"-"`                         // 判断错误是否需要重试，默认见 IsRetryableError
+}
+
+// IsRetryableError 默认的重试条件：限流（429）、服务端错误（500、502、503、504）、超时，
+// 以及Patch、Delete等不依赖resourceVersion的操作遇到的冲突（409）
+// 服务端应用的字段管理者冲突重试后仍会冲突，不重试
+// Update 的冲突重试会再次提交相同的resourceVersion，不会成功，请使用 GetAndUpdate
+func IsRetryableError(err error) bool {
+	if isTransientError(err) {
+		return true
+	}
+	return apierrors.IsConflict(err) && len(ApplyConflicts(err)) == 0
+}
+
+func isTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsInternalError(err) ||
+		apierrors.IsUnexpectedServerError(err) {
+		return true
+	}
+	if status, ok := err.(apierrors.APIStatus); ok {
+		switch status.Status().Code {
+		case 500, 502, 503, 504:
+			return true
+		}
+	}
+	return false
+}
+
+// retryable 错误是否需要重试
+func (p *RetryPolicy) retryable(operation string, err error) bool {
+	if err == nil {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	if operation == "update" && apierrors.IsConflict(err) {
+		return false
+	}
+	return IsRetryableError(err)
+}
+
+// retryAllowed 与重试策略无关、重试一定无意义或不安全的情况
+// create 未指定名称（generateName）时，首次请求实际已成功的话重试会创建重复的对象
+// Patch 中带有resourceVersion前置条件时，冲突说明对象已变化，重试仍会冲突
+func (k *Kubectl) retryAllowed(operation string, err error) bool {
+	stmt := k.Statement
+	switch operation {
+	case "create":
+		return createName(stmt) != ""
+	case "patch":
+		return !apierrors.IsConflict(err) || !patchHasResourceVersion(stmt.PatchData)
+	}
+	return true
+}
+
+// createName 创建对象的名称，只设置了generateName时为空
+func createName(stmt *Statement) string {
+	if stmt.Name != "" {
+		return stmt.Name
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(stmt.Dest)
+	if err != nil {
+		return ""
+	}
+	name, _, _ := unstructured.NestedString(obj, "metadata", "name")
+	return name
+}
+
+// patchHasResourceVersion Patch数据中是否带有resourceVersion前置条件
+// 支持JSON Merge Patch、Strategic Merge Patch、Apply（JSON或YAML）以及JSON Patch的test操作
+func patchHasResourceVersion(data string) bool {
+	if data == "" {
+		return false
+	}
+	var patch interface{}
+	if err := yaml.Unmarshal([]byte(data), &patch); err != nil {
+		return false
+	}
+	switch p := patch.(type) {
+	case map[string]interface{}:
+		rv, _, _ := unstructured.NestedFieldNoCopy(p, "metadata", "resourceVersion")
+		return rv != nil && rv != ""
+	case []interface{}:
+		for _, op := range p {
+			if m, ok := op.(map[string]interface{}); ok && m["path"] == "/metadata/resourceVersion" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// backoff 第attempt次重试（从0开始）前的等待时间
+func (p *RetryPolicy) backoff(attempt int, err error) time.Duration {
+	initial := p.InitialInterval
+	if initial <= 0 {
+		initial = defaultRetryInitialInterval
+	}
+	maxInterval := p.MaxInterval
+	if maxInterval <= 0 {
+		maxInterval = defaultRetryMaxInterval
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = defaultRetryMultiplier
+	}
+	jitter := p.Jitter
+	if jitter <= 0 || jitter > 1 {
+		jitter = defaultRetryJitter
+	}
+
+	interval := float64(initial) * math.Pow(multiplier, float64(attempt))
+	if interval > float64(maxInterval) {
+		interval = float64(maxInterval)
+	}
+	// 在 [interval*(1-jitter), interval*(1+jitter)] 范围内随机
+	interval = interval * (1 + jitter*(2*rand.Float64()-1))
+	wait := time.Duration(interval)
+
+	// 服务端要求的等待时间
+	if seconds, ok := apierrors.SuggestsClientDelay(err); ok {
+		if retryAfter := time.Duration(seconds) * time.Second; retryAfter > wait {
+			wait = retryAfter
+		}
+	}
+	return wait
+}
+
+// WithRetry 为本次操作设置重试策略，覆盖注册集群时的设置
+// 传入 RetryPolicy{} 则本次操作不重试
+func (k *Kubectl) WithRetry(policy RetryPolicy) *Kubectl {
+	tx := k.getInstance()
+	tx.Statement.RetryPolicy = &policy
+	return tx
+}
+
+// retryPolicy 当前生效的重试策略，未设置时返回nil
+func (k *Kubectl) retryPolicy() *RetryPolicy {
+	if k.Statement.RetryPolicy != nil {
+		return k.Statement.RetryPolicy
+	}
+	return k.parentCluster().Options.Retry
+}
+
+// withRetry 按重试策略执行fn，等待期间ctx取消时返回最后一次的错误
+func (k *Kubectl) withRetry(operation string, fn func() error) error {
+	err := fn()
+	if err == nil || !retryableOperations[operation] || !k.retryAllowed(operation, err) {
+		return err
+	}
+	policy := k.retryPolicy()
+	if policy == nil {
+		return err
+	}
+	ctx := k.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for attempt := 0; attempt < policy.MaxRetries && policy.retryable(operation, err); attempt++ {
+		wait := policy.backoff(attempt, err)
+		klog.V(4).Infof("cluster %s %s %s/%s failed, retry %d/%d after %s: %v",
+			k.ID, operation, k.Statement.Namespace, k.Statement.Name, attempt+1, policy.MaxRetries, wait, err)
+		if !sleepWithContext(ctx, wait) {
+			return err
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// sleepWithContext 等待d，ctx取消时提前返回false
+func sleepWithContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// GetAndUpdate 获取最新的资源，调用mutate修改后更新，遇到冲突（409）时重新获取并再次修改
+// dest 为资源对象指针，mutate 的参数即为获取到最新内容的dest
+// 重试次数按当前的重试策略，未设置时最多重试5次
+func (k *Kubectl) GetAndUpdate(dest interface{}, mutate func(obj interface{}) error) *Kubectl {
+	tx := k.getInstance()
+	maxRetries := defaultConflictRetries
+	policy := tx.retryPolicy()
+	if policy != nil {
+		maxRetries = policy.MaxRetries
+	} else {
+		policy = &RetryPolicy{}
+	}
+	ctx := tx.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	for attempt := 0; ; attempt++ {
+		// 不使用缓存，避免读到旧版本再次冲突
+		reader := tx.getInstance()
+		reader.Statement.CacheTTL = 0
+		reader.Statement.FromCache = false
+		if err := reader.Get(dest).Error; err != nil {
+			tx.Error = err
+			return tx
+		}
+		if err := mutate(dest); err != nil {
+			tx.Error = fmt.Errorf("GetAndUpdate mutate error: %w", err)
+			return tx
+		}
+		err := tx.Update(dest).Error
+		if err == nil || !apierrors.IsConflict(err) || attempt >= maxRetries {
+			tx.Error = err
+			return tx
+		}
+		wait := policy.backoff(attempt, err)
+		klog.V(4).Infof("cluster %s update %s/%s conflict, retry %d/%d after %s",
+			tx.ID, tx.Statement.Namespace, tx.Statement.Name, attempt+1, maxRetries, wait)
+		if !sleepWithContext(ctx, wait) {
+			tx.Error = err
+			return tx
+		}
+	}
+}
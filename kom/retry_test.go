@@ -0,0 +1,95 @@
+package kom
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestPatchHasResourceVersion(t *testing.T) {
+	cases := []struct {
+		data string
+		want bool
+	}{
+		{"", false},
+		{`{"metadata":{"labels":{"a":"b"}}}`, false},
+		{`{"metadata":{"resourceVersion":"1"}}`, true},
+		{`{"metadata":{"resourceVersion":""}}`, false},
+		{"metadata:\n  resourceVersion: \"1\"\n", true},
+		{`[{"op":"test","path":"/metadata/resourceVersion","value":"1"},{"op":"replace","path":"/spec/replicas","value":2}]`, true},
+		{`[{"op":"replace","path":"/spec/replicas","value":2}]`, false},
+		{`not a patch: [`, false},
+	}
+	for _, c := range cases {
+		if got := patchHasResourceVersion(c.data); got != c.want {
+			t.Errorf("patchHasResourceVersion(%q) want %v, got %v", c.data, c.want, got)
+		}
+	}
+}
+
+func TestRetryAllowed(t *testing.T) {
+	conflict := apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "test", fmt.Errorf("test"))
+	timeout := apierrors.NewTimeoutError("test", 1)
+	named := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	generated := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{GenerateName: "test-"}}
+	cases := []struct {
+		name      string
+		operation string
+		stmt      *Statement
+		err       error
+		want      bool
+	}{
+		{"create with name", "create", &Statement{Dest: named}, timeout, true},
+		{"create with statement name", "create", &Statement{Name: "test", Dest: generated}, timeout, true},
+		{"create with generateName", "create", &Statement{Dest: generated}, timeout, false},
+		{"patch conflict", "patch", &Statement{PatchData: `{"metadata":{"labels":{"a":"b"}}}`}, conflict, true},
+		{"patch conflict with precondition", "patch", &Statement{PatchData: `{"metadata":{"resourceVersion":"1"}}`}, conflict, false},
+		{"patch timeout with precondition", "patch", &Statement{PatchData: `{"metadata":{"resourceVersion":"1"}}`}, timeout, true},
+		{"get", "get", &Statement{}, timeout, true},
+	}
+	for _, c := range cases {
+		k := &Kubectl{Statement: c.stmt}
+		if got := k.retryAllowed(c.operation, c.err); got != c.want {
+			t.Errorf("%s: retryAllowed want %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := &RetryPolicy{InitialInterval: 100 * time.Millisecond, MaxInterval: time.Second, Multiplier: 2, Jitter: 0.2}
+	cases := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{0, 80 * time.Millisecond, 120 * time.Millisecond},
+		{2, 320 * time.Millisecond, 480 * time.Millisecond},
+		// 超过 MaxInterval 时以 MaxInterval 为准
+		{10, 800 * time.Millisecond, 1200 * time.Millisecond},
+	}
+	for _, c := range cases {
+		for i := 0; i < 100; i++ {
+			if wait := policy.backoff(c.attempt, nil); wait < c.min || wait > c.max {
+				t.Fatalf("attempt %d backoff want [%s, %s], got %s", c.attempt, c.min, c.max, wait)
+			}
+		}
+	}
+
+	// 未设置时使用默认值
+	if wait := (&RetryPolicy{}).backoff(0, nil); wait < 80*time.Millisecond || wait > 120*time.Millisecond {
+		t.Errorf("default backoff want about %s, got %s", defaultRetryInitialInterval, wait)
+	}
+
+	// 服务端返回的 Retry-After 长于退避时间时以其为准
+	if wait := policy.backoff(0, apierrors.NewTooManyRequests("test", 5)); wait != 5*time.Second {
+		t.Errorf("backoff with Retry-After want 5s, got %s", wait)
+	}
+	// Retry-After 短于退避时间时仍按退避时间等待
+	if wait := policy.backoff(10, apierrors.NewTooManyRequests("test", 0)); wait < 800*time.Millisecond {
+		t.Errorf("backoff should not be shortened by Retry-After, got %s", wait)
+	}
+}
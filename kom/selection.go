@@ -0,0 +1,204 @@
+package kom
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"sort"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// 默认同时操作的集群数量
+const defaultSelectionConcurrency = 5
+
+// ClusterResult 单个集群的执行结果
+type ClusterResult struct {
+	ClusterID string      `json:"clusterID"`
+	Result    interface{} `json:"result,omitempty"` // 执行结果，List、Get时为该集群对应的dest
+	Error     error       `json:"-"`
+}
+
+// ClusterSelection 按标签选中的一组集群，在选中的集群上并发执行相同的操作
+type ClusterSelection struct {
+	clusters    []*ClusterInst
+	concurrency int
+	chain       []func(k *Kubectl) *Kubectl // 在每个集群上执行前构建查询条件
+	err         error
+}
+
+// Tags 集群标签，返回副本，修改不影响集群
+func (ci *ClusterInst) Tags() map[string]string {
+	return maps.Clone(ci.Options.Tags)
+}
+
+// Select 按标签选择集群，语法与k8s标签选择器一致，如 env=prod,region in (eu,us)
+// 为空时选中所有集群
+func (c *ClusterInstances) Select(selector string) *ClusterSelection {
+	s := &ClusterSelection{concurrency: defaultSelectionConcurrency}
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		s.err = fmt.Errorf("invalid cluster selector %s: %v", selector, err)
+		return s
+	}
+	for _, cluster := range c.AllClusters() {
+		if sel.Matches(labels.Set(cluster.Tags())) {
+			s.clusters = append(s.clusters, cluster)
+		}
+	}
+	sort.Slice(s.clusters, func(i, j int) bool {
+		return s.clusters[i].ID < s.clusters[j].ID
+	})
+	return s
+}
+
+// IDs 选中的集群ID
+func (s *ClusterSelection) IDs() []string {
+	ids := make([]string, 0, len(s.clusters))
+	for _, cluster := range s.clusters {
+		ids = append(ids, cluster.ID)
+	}
+	return ids
+}
+
+// Concurrency 设置同时操作的集群数量，默认5
+func (s *ClusterSelection) Concurrency(n int) *ClusterSelection {
+	tx := s.clone()
+	if n > 0 {
+		tx.concurrency = n
+	}
+	return tx
+}
+
+// With 自定义查询条件，在每个集群上执行前调用
+func (s *ClusterSelection) With(fn func(k *Kubectl) *Kubectl) *ClusterSelection {
+	tx := s.clone()
+	tx.chain = append(tx.chain, fn)
+	return tx
+}
+func (s *ClusterSelection) Resource(obj runtime.Object) *ClusterSelection {
+	return s.With(func(k *Kubectl) *Kubectl {
+		return k.Resource(obj)
+	})
+}
+func (s *ClusterSelection) GVK(group string, version string, kind string) *ClusterSelection {
+	return s.With(func(k *Kubectl) *Kubectl {
+		return k.GVK(group, version, kind)
+	})
+}
+func (s *ClusterSelection) Namespace(namespaces ...string) *ClusterSelection {
+	return s.With(func(k *Kubectl) *Kubectl {
+		return k.Namespace(namespaces...)
+	})
+}
+func (s *ClusterSelection) Name(name string) *ClusterSelection {
+	return s.With(func(k *Kubectl) *Kubectl {
+		return k.Name(name)
+	})
+}
+func (s *ClusterSelection) WithLabelSelector(labelSelector string) *ClusterSelection {
+	return s.With(func(k *Kubectl) *Kubectl {
+		return k.WithLabelSelector(labelSelector)
+	})
+}
+
+// List 在选中的集群上查询资源列表
+// dest 仅用于确定结果类型，每个集群的结果为新建的同类型对象，放在 ClusterResult.Result 中
+func (s *ClusterSelection) List(dest interface{}, opt ...metav1.ListOptions) []*ClusterResult {
+	return s.Do(func(k *Kubectl) (interface{}, error) {
+		result, err := newDestLike(dest)
+		if err != nil {
+			return nil, err
+		}
+		err = k.List(result, opt...).Error
+		return result, err
+	})
+}
+
+// Get 在选中的集群上获取资源
+// dest 仅用于确定结果类型，每个集群的结果为新建的同类型对象，放在 ClusterResult.Result 中
+func (s *ClusterSelection) Get(dest interface{}) []*ClusterResult {
+	return s.Do(func(k *Kubectl) (interface{}, error) {
+		result, err := newDestLike(dest)
+		if err != nil {
+			return nil, err
+		}
+		err = k.Get(result).Error
+		return result, err
+	})
+}
+
+// Delete 在选中的集群上删除资源
+func (s *ClusterSelection) Delete() []*ClusterResult {
+	return s.Do(func(k *Kubectl) (interface{}, error) {
+		return nil, k.Delete().Error
+	})
+}
+
+// Apply 在选中的集群上应用yaml，结果为每个集群的 ApplyResults，有资源失败时 Error 为合并后的错误
+func (s *ClusterSelection) Apply(str string) []*ClusterResult {
+	return s.Do(func(k *Kubectl) (interface{}, error) {
+		results := k.Applier().Apply(str)
+		return results, results.Err()
+	})
+}
+
+// RestartDeployment 在选中的集群上重启Deployment，需先通过Namespace、Name指定
+func (s *ClusterSelection) RestartDeployment() []*ClusterResult {
+	return s.Do(func(k *Kubectl) (interface{}, error) {
+		return nil, k.Ctl().Deployment().Restart()
+	})
+}
+
+// Do 在选中的集群上并发执行fn，返回每个集群的结果，顺序与 IDs 一致
+func (s *ClusterSelection) Do(fn func(k *Kubectl) (interface{}, error)) []*ClusterResult {
+	if s.err != nil {
+		return []*ClusterResult{{Error: s.err}}
+	}
+	results := make([]*ClusterResult, len(s.clusters))
+	sem := make(chan struct{}, s.concurrency)
+	var wg sync.WaitGroup
+	for i, cluster := range s.clusters {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, cluster *ClusterInst) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			result := &ClusterResult{ClusterID: cluster.ID}
+			defer func() {
+				if r := recover(); r != nil {
+					result.Error = fmt.Errorf("cluster %s panic: %v", cluster.ID, r)
+				}
+				results[i] = result
+			}()
+			k := cluster.Kubectl
+			for _, chain := range s.chain {
+				k = chain(k)
+			}
+			result.Result, result.Error = fn(k)
+		}(i, cluster)
+	}
+	wg.Wait()
+	return results
+}
+
+func (s *ClusterSelection) clone() *ClusterSelection {
+	return &ClusterSelection{
+		clusters:    s.clusters,
+		concurrency: s.concurrency,
+		chain:       append([]func(k *Kubectl) *Kubectl{}, s.chain...),
+		err:         s.err,
+	}
+}
+
+// newDestLike 创建与dest同类型的新对象，dest需为指针，避免多个集群写入同一个对象
+func newDestLike(dest interface{}) (interface{}, error) {
+	t := reflect.TypeOf(dest)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("dest must be a pointer, got %T", dest)
+	}
+	return reflect.New(t.Elem()).Interface(), nil
+}
@@ -99,6 +99,39 @@ func (k *Kubectl) WithCache(ttl time.Duration) *Kubectl {
 	return tx
 }
 
+// FromCache 从集群共享的informer缓存中读取，仅对Get、List生效
+// 首次使用时为该资源启动informer，空闲一段时间后自动停止
+// Where、Order、Limit等条件与直接查询一致
+func (k *Kubectl) FromCache() *Kubectl {
+	tx := k.getInstance()
+	tx.Statement.FromCache = true
+	return tx
+}
+
+// DryRun 服务端试运行，Create、Update、Patch、Delete 经过API Server完整校验但不持久化
+// Create、Update、Patch 的dest为服务端将会生成的对象，可用于变更前预览
+// 基于这些操作的方法同样生效，如 Applier().Apply()、Ctl().Label()、Ctl().Scaler().Stop()
+func (k *Kubectl) DryRun() *Kubectl {
+	tx := k.getInstance()
+	tx.Statement.DryRun = true
+	return tx
+}
+
+// WithFieldManager 设置字段管理者名称，Create、Update、Patch 生效
+// 使用 types.ApplyPatchType 进行服务端应用时必须设置
+func (k *Kubectl) WithFieldManager(manager string) *Kubectl {
+	tx := k.getInstance()
+	tx.Statement.FieldManager = manager
+	return tx
+}
+
+// ForceConflicts 服务端应用时，强制接管由其他字段管理者管理的冲突字段
+func (k *Kubectl) ForceConflicts() *Kubectl {
+	tx := k.getInstance()
+	tx.Statement.ForceConflicts = true
+	return tx
+}
+
 func (k *Kubectl) CRD(group string, version string, kind string) *Kubectl {
 	return k.GVK(group, version, kind)
 }
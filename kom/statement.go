@@ -12,6 +12,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
 )
 
@@ -41,8 +42,15 @@ type Statement struct {
 	Filter              Filter                      `json:"filter,omitempty"`
 	StdoutCallback      func(data []byte) error     `json:"-"`
 	StderrCallback      func(data []byte) error     `json:"-"`
-	CacheTTL            time.Duration               `json:"cacheTTL,omitempty"`    // 设置缓存时间
-	ForceDelete         bool                        `json:"forceDelete,omitempty"` // 强制删除标志
+	CacheTTL            time.Duration               `json:"cacheTTL,omitempty"`       // 设置缓存时间
+	ForceDelete         bool                        `json:"forceDelete,omitempty"`    // 强制删除标志
+	FromCache           bool                        `json:"fromCache,omitempty"`      // 从informer缓存中读取，仅Get、List生效
+	Impersonate         *rest.ImpersonationConfig   `json:"impersonate,omitempty"`    // 模拟用户身份，通过As设置
+	BatchSize           int                         `json:"batchSize,omitempty"`      // 批量操作包含的对象数量，如 Applier().Delete，供钩子检查使用
+	DryRun              bool                        `json:"dryRun,omitempty"`         // 服务端试运行，不持久化变更，通过DryRun设置
+	RetryPolicy         *RetryPolicy                `json:"retryPolicy,omitempty"`    // 失败重试策略，通过WithRetry设置，为nil时使用集群的设置
+	FieldManager        string                      `json:"fieldManager,omitempty"`   // 字段管理者名称，通过WithFieldManager设置
+	ForceConflicts      bool                        `json:"forceConflicts,omitempty"` // 服务端应用时强制接管冲突字段，通过ForceConflicts设置
 }
 type Filter struct {
 	Columns    []string    `json:"columns,omitempty"`
@@ -127,3 +135,23 @@ func (s *Statement) ParseFromRuntimeObj(obj runtime.Object) *Statement {
 		ParseGVKFromRuntimeObj(obj).
 		ParseNsNameFromRuntimeObj(obj)
 }
+
+// Target 操作对象的命名空间及名称，未通过 Namespace、Name 指定时从Dest中获取
+// 命名空间级资源未指定命名空间时为 default，供插件及回调识别操作对象
+func (s *Statement) Target() (ns, name string) {
+	ns, name = s.Namespace, s.Name
+	if obj, ok := s.Dest.(runtime.Object); ok {
+		if accessor, err := meta.Accessor(obj); err == nil {
+			if name == "" {
+				name = accessor.GetName()
+			}
+			if ns == "" {
+				ns = accessor.GetNamespace()
+			}
+		}
+	}
+	if s.Namespaced && ns == "" {
+		ns = metav1.NamespaceDefault
+	}
+	return ns, name
+}
@@ -2,7 +2,7 @@ package kom
 
 import (
 	"context"
-	"strings"
+	"fmt"
 	"time"
 
 	"github.com/google/gnostic-models/openapiv2"
@@ -20,80 +20,137 @@ type status struct {
 	kubectl *Kubectl
 }
 
+// APIResources 集群已注册资源，首次调用时获取，集群不可达时返回空
 func (s *status) APIResources() []*metav1.APIResource {
 	cluster := s.kubectl.parentCluster()
-	return cluster.apiResources
+	_ = s.kubectl.ensureDiscovery()
+	apiResources, _ := cluster.discovery.get()
+	return apiResources
 }
+
+// CRDList 集群已注册CRD，首次调用时获取，集群不可达时返回空
 func (s *status) CRDList() []*unstructured.Unstructured {
 	cluster := s.kubectl.parentCluster()
-	return cluster.crdList
+	_ = s.kubectl.ensureDiscovery()
+	_, crdList := cluster.discovery.get()
+	return crdList
 }
+
+// Docs 集群文档，未在注册时加载的，首次调用时加载
 func (s *status) Docs() *doc.Docs {
 	cluster := s.kubectl.parentCluster()
-	return cluster.docs
+	return cluster.loadDocs()
 }
+
+// ServerVersion 集群版本信息，首次调用时获取，集群不可达时返回nil
 func (s *status) ServerVersion() *version.Info {
 	cluster := s.kubectl.parentCluster()
-	return cluster.serverVersion
+	versionInfo, _ := cluster.loadServerVersion()
+	return versionInfo
+}
+
+// State 集群连接状态
+func (s *status) State() ClusterState {
+	return s.kubectl.parentCluster().State()
+}
+
+// Health 集群健康状态
+func (s *status) Health() ClusterHealth {
+	return s.kubectl.parentCluster().Health()
 }
+
+// DescriberMap 集群描述器，未在注册时初始化的，首次调用时初始化
+// 设置了模拟身份时，返回以该身份访问的描述器，按该用户的RBAC权限读取关联的事件等资源
 func (s *status) DescriberMap() map[schema.GroupKind]describe.ResourceDescriber {
+	if clients := s.kubectl.impersonatedClients(); clients != nil {
+		return clients.describers()
+	}
+	cluster := s.kubectl.parentCluster()
+	return cluster.loadDescriberMap()
+}
+
+// CacheStats 查询缓存统计信息
+func (s *status) CacheStats() *CacheStats {
 	cluster := s.kubectl.parentCluster()
-	return cluster.describerMap
+	cache := cluster.Cache
+	if cache == nil {
+		return &CacheStats{Disabled: true}
+	}
+	m := cache.Metrics
+	stats := &CacheStats{
+		Hits:        m.Hits(),
+		Misses:      m.Misses(),
+		HitRatio:    m.Ratio(),
+		KeysAdded:   m.KeysAdded(),
+		KeysUpdated: m.KeysUpdated(),
+		KeysEvicted: m.KeysEvicted(),
+		SetsDropped: m.SetsDropped(),
+		Bytes:       int64(m.CostAdded()) - int64(m.CostEvicted()),
+		MaxBytes:    cache.MaxCost(),
+		TrackedKeys: cluster.cacheIndex.count(),
+		Shared:      cluster.sharedCache,
+	}
+	return stats
 }
 
 // 获取版本信息
-func (k *Kubectl) initializeServerVersion() *version.Info {
+func (k *Kubectl) initializeServerVersion() (*version.Info, error) {
 	versionInfo, err := k.Client().Discovery().ServerVersion()
 	if err != nil {
 		klog.V(2).Infof("Error getting server version: %v\n", err)
+		return nil, err
 	}
-	return versionInfo
+	return versionInfo, nil
 }
 
-func (k *Kubectl) getOpenAPISchema() *openapi_v2.Document {
+func (k *Kubectl) getOpenAPISchema() (*openapi_v2.Document, error) {
 	openAPISchema, err := k.Client().Discovery().OpenAPISchema()
 	if err != nil {
 		klog.V(2).Infof("Error fetching OpenAPI schema: %v\n", err)
-		return nil
+		return nil, err
 	}
-	return openAPISchema
+	return openAPISchema, nil
 }
 
-func (k *Kubectl) initializeCRDList(ttl time.Duration) []*unstructured.Unstructured {
-	cache, err := utils.GetOrSetCache(k.ClusterCache(), "crdList", ttl, func() (ret []*unstructured.Unstructured, err error) {
-		crdList, err := k.listResources(context.TODO(), "CustomResourceDefinition", "")
-		return crdList, err
+func (k *Kubectl) initializeCRDList(ttl time.Duration) ([]*unstructured.Unstructured, error) {
+	return utils.GetOrSetCache(k.ClusterCache(), k.crdListCacheKey(), ttl, func() (ret []*unstructured.Unstructured, err error) {
+		return k.listCRDs(context.TODO())
 	})
-	if err != nil {
-		return nil
-	}
-	return cache
-
-}
-func (k *Kubectl) initializeAPIResources() (apiResources []*metav1.APIResource) {
-	// 提取ApiResources
-	_, lists, _ := k.Client().Discovery().ServerGroupsAndResources()
-	for _, list := range lists {
-		resources := list.APIResources
-		ver := list.GroupVersionKind().Version
-		group := list.GroupVersionKind().Group
-		groupVersion := list.GroupVersion
-		gvs := strings.Split(groupVersion, "/")
-		if len(gvs) == 2 {
-			group = gvs[0]
-			ver = gvs[1]
-		} else {
-			// 只有version的情况"v1"
-			ver = groupVersion
-		}
+}
+
+// crdListCacheKey CRD列表的缓存key，带有集群ID，避免共享缓存时不同集群冲突
+func (k *Kubectl) crdListCacheKey() string {
+	return fmt.Sprintf("%s/crdList", k.ID)
+}
+
+// loadServerVersion 获取版本信息，成功后不再重复获取
+func (ci *ClusterInst) loadServerVersion() (*version.Info, error) {
+	return ci.serverVersion.get(ci.Kubectl.initializeServerVersion)
+}
 
-		for _, resource := range resources {
-			resource.Group = group
-			resource.Version = ver
-			apiResources = append(apiResources, &resource)
+// reloadServerVersion 重新获取版本信息，集群升级或重新连接后使用
+func (ci *ClusterInst) reloadServerVersion() (*version.Info, error) {
+	return ci.serverVersion.reload(ci.Kubectl.initializeServerVersion)
+}
+
+// loadDocs 加载OpenAPI文档，成功后不再重复加载
+func (ci *ClusterInst) loadDocs() *doc.Docs {
+	docs, _ := ci.docs.get(func() (*doc.Docs, error) {
+		openAPISchema, err := ci.Kubectl.getOpenAPISchema()
+		if err != nil {
+			return nil, err
 		}
-	}
-	return apiResources
+		return doc.InitTrees(openAPISchema), nil
+	})
+	return docs
+}
+
+// loadDescriberMap 初始化描述器，只初始化一次
+func (ci *ClusterInst) loadDescriberMap() map[schema.GroupKind]describe.ResourceDescriber {
+	describerMap, _ := ci.describerMap.get(func() (map[schema.GroupKind]describe.ResourceDescriber, error) {
+		return ci.Kubectl.initializeDescriberMap(), nil
+	})
+	return describerMap
 }
 func (k *Kubectl) initializeDescriberMap() map[schema.GroupKind]describe.ResourceDescriber {
 	return describe.InitializeDescriberMap(k.RestConfig())
@@ -0,0 +1,287 @@
+package kom
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// ClusterRecord 持久化的集群定义
+type ClusterRecord struct {
+	ID         string          `json:"id"`
+	Kubeconfig string          `json:"kubeconfig"`          // kubeconfig内容，设置了加密key时为加密后的base64
+	Encrypted  bool            `json:"encrypted,omitempty"` // kubeconfig是否已加密
+	Options    RegisterOptions `json:"options,omitempty"`   // 注册参数，包括标签
+}
+
+// ClusterStore 集群定义的持久化存储
+// 设置后，注册集群时保存，删除集群时移除，启动时通过 LoadFromStore 重新注册
+type ClusterStore interface {
+	Load() ([]*ClusterRecord, error)
+	Save(record *ClusterRecord) error
+	Delete(id string) error
+}
+
+// fileClusterStore 基于文件的集群定义存储，按扩展名使用YAML或JSON格式
+type fileClusterStore struct {
+	mu   sync.Mutex
+	path string
+	gcm  cipher.AEAD // 为nil时不加密
+}
+
+// NewFileClusterStore 创建基于文件的集群定义存储
+// path 以 .yaml 或 .yml 结尾时使用YAML格式，否则使用JSON格式
+// key 不为空时，使用AES-GCM加密kubeconfig内容，长度需为16、24或32字节
+func NewFileClusterStore(path string, key []byte) (ClusterStore, error) {
+	s := &fileClusterStore{path: path}
+	if len(key) > 0 {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key: %v", err)
+		}
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key: %v", err)
+		}
+		s.gcm = gcm
+	}
+	return s, nil
+}
+
+// Load 读取全部集群定义，文件不存在时返回空
+func (s *fileClusterStore) Load() ([]*ClusterRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if err := s.decrypt(record); err != nil {
+			return nil, fmt.Errorf("decrypt cluster %s error: %v", record.ID, err)
+		}
+	}
+	return records, nil
+}
+
+// Save 保存集群定义，ID相同时覆盖
+func (s *fileClusterStore) Save(record *ClusterRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records, err := s.read()
+	if err != nil {
+		return err
+	}
+	saved := *record
+	if err := s.encrypt(&saved); err != nil {
+		return fmt.Errorf("encrypt cluster %s error: %v", record.ID, err)
+	}
+	replaced := false
+	for i, r := range records {
+		if r.ID == saved.ID {
+			records[i] = &saved
+			replaced = true
+		}
+	}
+	if !replaced {
+		records = append(records, &saved)
+	}
+	return s.write(records)
+}
+
+// Delete 删除集群定义
+func (s *fileClusterStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records, err := s.read()
+	if err != nil {
+		return err
+	}
+	result := make([]*ClusterRecord, 0, len(records))
+	for _, r := range records {
+		if r.ID != id {
+			result = append(result, r)
+		}
+	}
+	if len(result) == len(records) {
+		return nil
+	}
+	return s.write(result)
+}
+
+func (s *fileClusterStore) isYAML() bool {
+	ext := strings.ToLower(filepath.Ext(s.path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func (s *fileClusterStore) read() ([]*ClusterRecord, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read cluster store %s error: %v", s.path, err)
+	}
+	var records []*ClusterRecord
+	if s.isYAML() {
+		err = yaml.Unmarshal(data, &records)
+	} else if len(data) > 0 {
+		err = json.Unmarshal(data, &records)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse cluster store %s error: %v", s.path, err)
+	}
+	return records, nil
+}
+
+// write 先写入临时文件再重命名，避免写入中断时损坏原文件
+func (s *fileClusterStore) write(records []*ClusterRecord) error {
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID < records[j].ID
+	})
+	var data []byte
+	var err error
+	if s.isYAML() {
+		data, err = yaml.Marshal(records)
+	} else {
+		data, err = json.MarshalIndent(records, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	// 包含kubeconfig，仅允许当前用户读写
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write cluster store %s error: %v", s.path, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("write cluster store %s error: %v", s.path, err)
+	}
+	return nil
+}
+
+func (s *fileClusterStore) encrypt(record *ClusterRecord) error {
+	if s.gcm == nil || record.Encrypted {
+		return nil
+	}
+	nonce := make([]byte, s.gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	sealed := s.gcm.Seal(nonce, nonce, []byte(record.Kubeconfig), []byte(record.ID))
+	record.Kubeconfig = base64.StdEncoding.EncodeToString(sealed)
+	record.Encrypted = true
+	return nil
+}
+
+func (s *fileClusterStore) decrypt(record *ClusterRecord) error {
+	if !record.Encrypted {
+		return nil
+	}
+	if s.gcm == nil {
+		return fmt.Errorf("kubeconfig is encrypted, but no key is provided")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(record.Kubeconfig)
+	if err != nil {
+		return err
+	}
+	if len(sealed) < s.gcm.NonceSize() {
+		return fmt.Errorf("invalid encrypted kubeconfig")
+	}
+	nonce, ciphertext := sealed[:s.gcm.NonceSize()], sealed[s.gcm.NonceSize():]
+	plain, err := s.gcm.Open(nil, nonce, ciphertext, []byte(record.ID))
+	if err != nil {
+		return err
+	}
+	record.Kubeconfig = string(plain)
+	record.Encrypted = false
+	return nil
+}
+
+// SetStore 设置集群定义的持久化存储
+// 之后通过kubeconfig注册的集群会保存到存储中，删除集群时从存储中移除
+func (c *ClusterInstances) SetStore(store ClusterStore) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store = store
+}
+
+// LoadFromStore 从存储中读取集群定义并注册，注册时不再写回存储
+// 部分集群注册失败时，其余集群仍会注册，返回失败的原因
+func (c *ClusterInstances) LoadFromStore() error {
+	c.mu.RLock()
+	store := c.store
+	c.mu.RUnlock()
+	if store == nil {
+		return fmt.Errorf("cluster store is not set")
+	}
+	records, err := store.Load()
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, record := range records {
+		if err := c.registerRecord(record); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("LoadFromStore Error errs:[%s]", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// registerRecord 注册存储中的集群定义，kubeconfig保存时已精简，不再处理
+func (c *ClusterInstances) registerRecord(record *ClusterRecord) error {
+	data := []byte(record.Kubeconfig)
+	config, err := clientcmd.RESTConfigFromKubeConfig(data)
+	if err != nil {
+		return fmt.Errorf("LoadFromStore Error id:%s,err:%v", record.ID, err)
+	}
+	_, err = c.register(config, record.ID, data, withPathDefaults([]RegisterOptions{record.Options}), false)
+	return err
+}
+
+// saveToStore 注册成功后保存集群定义，没有kubeconfig内容的集群（如InCluster）不保存
+func (c *ClusterInstances) saveToStore(cluster *ClusterInst) {
+	c.mu.RLock()
+	store := c.store
+	c.mu.RUnlock()
+	if store == nil || len(cluster.kubeconfig) == 0 {
+		return
+	}
+	err := store.Save(&ClusterRecord{
+		ID:         cluster.ID,
+		Kubeconfig: string(cluster.kubeconfig),
+		Options:    cluster.Options,
+	})
+	if err != nil {
+		klog.Errorf("save cluster %s to store error: %v", cluster.ID, err)
+	}
+}
+
+// deleteFromStore 删除集群后从存储中移除
+func (c *ClusterInstances) deleteFromStore(id string) {
+	c.mu.RLock()
+	store := c.store
+	c.mu.RUnlock()
+	if store == nil {
+		return
+	}
+	if err := store.Delete(id); err != nil {
+		klog.Errorf("delete cluster %s from store error: %v", id, err)
+	}
+}
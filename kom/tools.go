@@ -8,14 +8,56 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
 )
 
 type tools struct {
 	kubectl *Kubectl
 }
 
+// ClearCache 清空集群查询缓存
+// 使用全局共享缓存时，只清除本集群登记过的缓存key
 func (u *tools) ClearCache() {
-	u.kubectl.ClusterCache().Clear()
+	cluster := u.kubectl.parentCluster()
+	keys := cluster.cacheIndex.reset()
+	if cluster.Cache == nil {
+		return
+	}
+	if cluster.sharedCache {
+		for _, key := range keys {
+			cluster.Cache.Del(key)
+		}
+		cluster.Cache.Del(u.kubectl.crdListCacheKey())
+		return
+	}
+	cluster.Cache.Clear()
+}
+
+// TrackCacheKey 登记查询缓存key对应的资源，资源变更后据此失效缓存
+// ns 为空表示集群级资源或全部命名空间，name 为空表示列表查询
+func (u *tools) TrackCacheKey(gvr schema.GroupVersionResource, ns string, name string, cacheKey string) {
+	u.kubectl.parentCluster().cacheIndex.track(gvr, ns, name, cacheKey)
+}
+
+// InvalidateCache 失效指定GVR、命名空间下的查询缓存
+// ns 为空时失效该GVR下的全部缓存
+// 传入names时，只失效列表查询以及对应名称资源的缓存
+func (u *tools) InvalidateCache(gvr schema.GroupVersionResource, ns string, names ...string) {
+	cache := u.kubectl.ClusterCache()
+	index := u.kubectl.parentCluster().cacheIndex
+	var keys []string
+	if len(names) == 0 {
+		keys = index.match(gvr, ns, "")
+	}
+	for _, name := range names {
+		keys = append(keys, index.match(gvr, ns, name)...)
+	}
+	for _, key := range keys {
+		cache.Del(key)
+	}
+	if len(keys) > 0 {
+		klog.V(5).Infof("invalidate %d cache keys of %s %s/%v", len(keys), gvr.String(), ns, names)
+	}
 }
 
 // ConvertRuntimeObjectToTypedObject 是一个通用的转换函数，将 runtime.Object 转换为指定的目标类型
@@ -204,7 +246,7 @@ func (u *tools) GetGVK(gvks []schema.GroupVersionKind, versions ...string) (gvk
 // APIResource 包含了CRD的内容
 func (u *tools) FindGVKByTableNameInApiResources(tableName string) *schema.GroupVersionKind {
 
-	for _, resource := range u.kubectl.parentCluster().apiResources {
+	for _, resource := range u.kubectl.Status().APIResources() {
 		// 比较表名和资源名 (Name) 或 Kind
 		if resource.Name == tableName || resource.Kind == tableName || resource.SingularName == tableName ||
 			slice.Contain(resource.ShortNames, tableName) {
@@ -222,7 +264,7 @@ func (u *tools) FindGVKByTableNameInApiResources(tableName string) *schema.Group
 // FindGVKByTableNameInCRDList 从CRD列表中找到对应的表名的GVK
 func (u *tools) FindGVKByTableNameInCRDList(tableName string) *schema.GroupVersionKind {
 
-	for _, crd := range u.kubectl.parentCluster().crdList {
+	for _, crd := range u.kubectl.Status().CRDList() {
 		// 从 CRD 对象中获取 "spec" 下的 names 字段
 		specNames, found, err := unstructured.NestedMap(crd.Object, "spec", "names")
 		if err != nil || !found {
@@ -262,7 +304,7 @@ func (u *tools) FindGVKByTableNameInCRDList(tableName string) *schema.GroupVersi
 	return nil // 未找到匹配项
 }
 func (u *tools) ListAvailableTableNames() (names []string) {
-	for _, resource := range u.kubectl.parentCluster().apiResources {
+	for _, resource := range u.kubectl.Status().APIResources() {
 		// 比较表名和资源名 (Name) 或 Kind
 		names = append(names, strings.ToLower(resource.Kind))
 		for _, name := range resource.ShortNames {
@@ -0,0 +1,150 @@
+package kom
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Tracer 链路追踪，通过 SetTracer 设置，可对接OpenTelemetry等实现
+// kom 在每次操作（get、list、patch等）以及每个API请求前后创建Span，
+// 父Span从 Statement.Context 中获取，可通过 WithContext 传入
+type Tracer interface {
+	// StartSpan 以ctx中的Span为父Span创建子Span，返回携带新Span的ctx
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span 一段追踪
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	End()
+}
+
+// Attribute Span属性
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// Attr 创建Span属性
+func Attr(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+var (
+	tracerMu sync.RWMutex
+	tracer   Tracer
+)
+
+// SetTracer 设置全局的链路追踪，为nil时关闭
+func SetTracer(t Tracer) {
+	tracerMu.Lock()
+	defer tracerMu.Unlock()
+	tracer = t
+}
+
+func currentTracer() Tracer {
+	tracerMu.RLock()
+	defer tracerMu.RUnlock()
+	return tracer
+}
+
+// noopSpan 未设置Tracer时使用
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) End()                       {}
+
+// startSpan 创建Span，未设置Tracer时原样返回ctx
+func startSpan(ctx context.Context, name string) (context.Context, Span) {
+	t := currentTracer()
+	if t == nil {
+		return ctx, noopSpan{}
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return t.StartSpan(ctx, name)
+}
+
+// startSpan 以当前Statement的ctx创建Span，返回使用新ctx的实例
+// 内部发起多次请求的方法通过返回的实例执行，各请求即成为该Span的子Span
+// 返回的是复制的实例，不修改当前实例的ctx，Span结束后的操作不会挂在该Span下
+func (k *Kubectl) startSpan(name string) (*Kubectl, Span) {
+	ctx, span := startSpan(k.Statement.Context, name)
+	if _, ok := span.(noopSpan); ok {
+		return k, span
+	}
+	span.SetAttributes(k.spanAttributes()...)
+	tx := k.copyInstance()
+	tx.Statement.Context = ctx
+	return tx, span
+}
+
+// withSpanContext 复制实例及Statement并使用Span的ctx，Dest等指针字段与当前实例共用，
+// 操作结果仍写入调用方传入的对象
+func (k *Kubectl) withSpanContext(ctx context.Context) *Kubectl {
+	stmt := *k.Statement
+	stmt.Context = ctx
+	tx := *k
+	tx.Statement = &stmt
+	return &tx
+}
+
+// spanAttributes 当前操作对象的属性
+func (k *Kubectl) spanAttributes() []Attribute {
+	stmt := k.Statement
+	attrs := []Attribute{Attr("kom.cluster", k.ID)}
+	if gvr := gvrLabel(stmt.GVR); gvr != "" {
+		attrs = append(attrs, Attr("kom.gvr", gvr))
+	}
+	if stmt.Namespace != "" {
+		attrs = append(attrs, Attr("kom.namespace", stmt.Namespace))
+	}
+	if stmt.Name != "" {
+		attrs = append(attrs, Attr("kom.name", stmt.Name))
+	}
+	if stmt.DryRun {
+		attrs = append(attrs, Attr("kom.dry_run", "true"))
+	}
+	return attrs
+}
+
+// tracingRoundTripper 为每个API请求创建Span
+type tracingRoundTripper struct {
+	clusterID string
+	rt        http.RoundTripper
+}
+
+// newTracingRoundTripper 通过 rest.Config.Wrap 注册，未设置Tracer时直接转发
+func newTracingRoundTripper(clusterID string) func(rt http.RoundTripper) http.RoundTripper {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &tracingRoundTripper{clusterID: clusterID, rt: rt}
+	}
+}
+
+func (t *tracingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if currentTracer() == nil {
+		return t.rt.RoundTrip(req)
+	}
+	ctx, span := startSpan(req.Context(), "HTTP "+req.Method)
+	defer span.End()
+	span.SetAttributes(
+		Attr("kom.cluster", t.clusterID),
+		Attr("http.method", req.Method),
+		Attr("http.url", req.URL.String()),
+	)
+	resp, err := t.rt.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		span.SetAttributes(Attr("error", err.Error()))
+		return resp, err
+	}
+	span.SetAttributes(Attr("http.status_code", strconv.Itoa(resp.StatusCode)))
+	return resp, nil
+}
+
+// WrappedRoundTripper 供client-go读取底层的RoundTripper
+func (t *tracingRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return t.rt
+}
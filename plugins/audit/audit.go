@@ -0,0 +1,244 @@
+// Package audit 审计插件，记录kom执行的每一次变更操作
+//
+// 通过 kom.Clusters().SetRegisterCallbackFunc 为每个集群注册钩子，
+// 记录集群、资源、操作、调用者、耗时以及结果，写入可替换的 Sink。
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/weibaohui/kom/kom"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+)
+
+// 钩子名称
+const hookName = "kom:audit"
+
+// Record 一条审计记录
+type Record struct {
+	Time        time.Time `json:"time"`
+	ClusterID   string    `json:"clusterID"`
+	Operation   string    `json:"operation"` // create、update、patch、delete、evict、exec、stream-exec
+	Group       string    `json:"group,omitempty"`
+	Version     string    `json:"version,omitempty"`
+	Kind        string    `json:"kind,omitempty"`
+	Namespace   string    `json:"namespace,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	Caller      string    `json:"caller,omitempty"`      // 调用者，通过 WithCaller 设置在context中
+	Impersonate string    `json:"impersonate,omitempty"` // 通过As模拟的用户
+	DryRun      bool      `json:"dryRun,omitempty"`      // 服务端试运行，未实际变更
+	PatchType   string    `json:"patchType,omitempty"`
+	PatchData   string    `json:"patchData,omitempty"`
+	Diff        string    `json:"diff,omitempty"` // 变更前后对象的差异，JSON Merge Patch格式，需开启 Options.Diff
+	Container   string    `json:"container,omitempty"`
+	Command     string    `json:"command,omitempty"`
+	Args        []string  `json:"args,omitempty"`
+	DurationMs  int64     `json:"durationMs"`
+	Success     bool      `json:"success"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// Sink 审计记录的输出
+type Sink interface {
+	Write(record *Record) error
+}
+
+// Options 审计插件配置
+type Options struct {
+	Sink       Sink                             // 审计记录输出，必填
+	Diff       bool                             // 记录update、patch前后对象的差异，会在变更前多一次Get请求
+	Operations []string                         // 需要审计的操作，默认为所有变更操作
+	CallerFunc func(ctx context.Context) string // 从context中获取调用者，默认读取 WithCaller 设置的值
+}
+
+// 默认审计的变更操作
+var defaultOperations = []string{"create", "update", "patch", "delete", "evict", "exec", "stream-exec"}
+
+type callerKey struct{}
+
+// WithCaller 在context中设置调用者，配合 kom.Kubectl.WithContext 使用
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext 获取 WithCaller 设置的调用者
+func CallerFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
+
+// auditor 审计钩子
+type auditor struct {
+	opts      Options
+	snapshots sync.Map // 变更前的对象，key为本次执行的 *kom.Kubectl
+}
+
+// Install 安装审计插件
+// 为已注册的集群注册审计钩子，并在原有回调注册函数的基础上追加，之后注册的集群同样生效
+// 需在 callbacks.RegisterInit 之后调用
+func Install(opts Options) {
+	if len(opts.Operations) == 0 {
+		opts.Operations = defaultOperations
+	}
+	if opts.CallerFunc == nil {
+		opts.CallerFunc = CallerFromContext
+	}
+	a := &auditor{opts: opts}
+
+	previous := kom.Clusters().GetRegisterCallbackFunc()
+	kom.Clusters().SetRegisterCallbackFunc(func(cluster *kom.ClusterInst) func() {
+		if previous != nil {
+			previous(cluster)
+		}
+		a.register(cluster)
+		return nil
+	})
+	for _, cluster := range kom.Clusters().AllClusters() {
+		a.register(cluster)
+	}
+}
+
+// register 为集群的变更操作注册审计钩子
+func (a *auditor) register(cluster *kom.ClusterInst) {
+	k := cluster.Kubectl
+	for _, op := range a.opts.Operations {
+		p := k.Callback().Processor(op)
+		if p == nil {
+			klog.V(2).Infof("audit: unknown operation %s", op)
+			continue
+		}
+		if a.opts.Diff && (op == "update" || op == "patch") {
+			p.BeforeHook(hookName, a.snapshot)
+		}
+		p.AfterHook(hookName, a.record)
+	}
+}
+
+// snapshot 变更前获取当前对象，用于计算差异
+func (a *auditor) snapshot(k *kom.Kubectl) error {
+	stmt := k.Statement
+	ns, name := stmt.Target()
+	if name == "" {
+		return nil
+	}
+	var obj *unstructured.Unstructured
+	var err error
+	if stmt.Namespaced {
+		obj, err = k.DynamicClient().Resource(stmt.GVR).Namespace(ns).Get(stmt.Context, name, metav1.GetOptions{})
+	} else {
+		obj, err = k.DynamicClient().Resource(stmt.GVR).Get(stmt.Context, name, metav1.GetOptions{})
+	}
+	if err == nil {
+		a.snapshots.Store(k, obj.Object)
+	}
+	// 获取失败不影响变更操作
+	return nil
+}
+
+// record 记录审计日志
+func (a *auditor) record(k *kom.Kubectl, result *kom.ExecResult) {
+	stmt := k.Statement
+	ns, name := stmt.Target()
+	record := &Record{
+		Time:       result.StartTime,
+		ClusterID:  k.ID,
+		Operation:  result.Operation,
+		Group:      stmt.GVK.Group,
+		Version:    stmt.GVK.Version,
+		Kind:       stmt.GVK.Kind,
+		Namespace:  ns,
+		Name:       name,
+		Caller:     a.opts.CallerFunc(stmt.Context),
+		DurationMs: result.Duration.Milliseconds(),
+		Success:    result.Error == nil,
+		DryRun:     stmt.DryRun,
+	}
+	if stmt.Impersonate != nil {
+		record.Impersonate = stmt.Impersonate.UserName
+	}
+	if result.Error != nil {
+		record.Error = result.Error.Error()
+	}
+	switch result.Operation {
+	case "patch":
+		record.PatchType = string(stmt.PatchType)
+		record.PatchData = stmt.PatchData
+	case "exec", "stream-exec":
+		record.Container = stmt.ContainerName
+		record.Command = stmt.Command
+		record.Args = stmt.Args
+	}
+	if before, ok := a.snapshots.LoadAndDelete(k); ok && result.Error == nil {
+		record.Diff = diff(before.(map[string]interface{}), result.Result)
+	}
+	if err := a.opts.Sink.Write(record); err != nil {
+		klog.Errorf("audit: write record error: %v", err)
+	}
+}
+
+// diff 计算变更前后对象的差异
+func diff(before map[string]interface{}, after interface{}) string {
+	if after == nil {
+		return ""
+	}
+	afterMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(after)
+	if err != nil {
+		return ""
+	}
+	// 忽略每次变更都会变化的字段
+	for _, obj := range []map[string]interface{}{before, afterMap} {
+		unstructured.RemoveNestedField(obj, "metadata", "resourceVersion")
+		unstructured.RemoveNestedField(obj, "metadata", "generation")
+		unstructured.RemoveNestedField(obj, "metadata", "managedFields")
+		unstructured.RemoveNestedField(obj, "status")
+	}
+	patch := mergePatch(before, afterMap)
+	if len(patch) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// mergePatch 生成从before到after的JSON Merge Patch（RFC 7386）
+func mergePatch(before, after map[string]interface{}) map[string]interface{} {
+	patch := map[string]interface{}{}
+	for key, afterValue := range after {
+		beforeValue, ok := before[key]
+		if !ok {
+			patch[key] = afterValue
+			continue
+		}
+		beforeMap, beforeIsMap := beforeValue.(map[string]interface{})
+		afterMap, afterIsMap := afterValue.(map[string]interface{})
+		if beforeIsMap && afterIsMap {
+			if sub := mergePatch(beforeMap, afterMap); len(sub) > 0 {
+				patch[key] = sub
+			}
+			continue
+		}
+		beforeJSON, _ := json.Marshal(beforeValue)
+		afterJSON, _ := json.Marshal(afterValue)
+		if string(beforeJSON) != string(afterJSON) {
+			patch[key] = afterValue
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			patch[key] = nil
+		}
+	}
+	return patch
+}
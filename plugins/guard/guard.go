@@ -0,0 +1,156 @@
+// Package guard 策略防护插件，在变更操作执行前按规则检查，拒绝危险操作
+//
+// 通过 kom.Clusters().SetRegisterCallbackFunc 为每个集群注册前置钩子，
+// 命中规则时终止操作，返回 *ErrPolicyDenied。
+package guard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/weibaohui/kom/kom"
+	"k8s.io/klog/v2"
+)
+
+// 钩子名称
+const hookName = "kom:guard"
+
+// 默认检查的变更操作
+var mutatingOperations = []string{"create", "update", "patch", "delete", "evict", "exec", "stream-exec"}
+
+// ErrPolicyDenied 操作被策略拒绝
+type ErrPolicyDenied struct {
+	Rule      string // 命中的规则名称
+	Reason    string // 拒绝原因
+	ClusterID string
+	Operation string
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (e *ErrPolicyDenied) Error() string {
+	target := e.Name
+	if e.Namespace != "" {
+		target = e.Namespace + "/" + e.Name
+	}
+	return fmt.Sprintf("%s %s %s denied by policy %s: %s", e.Operation, e.Kind, target, e.Rule, e.Reason)
+}
+
+// IsPolicyDenied 判断错误是否为策略拒绝
+func IsPolicyDenied(err error) bool {
+	var denied *ErrPolicyDenied
+	return errors.As(err, &denied)
+}
+
+// Request 待检查的操作
+type Request struct {
+	Kubectl   *kom.Kubectl // 本次执行的实例，可通过 Statement 获取完整的查询条件
+	ClusterID string
+	Operation string // create、update、patch、delete、exec、stream-exec
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// Rule 防护规则
+type Rule struct {
+	Name       string                   // 规则名称，拒绝时在 ErrPolicyDenied.Rule 中返回
+	Operations []string                 // 适用的操作，为空时适用于所有变更操作
+	Check      func(req *Request) error // 返回错误时拒绝，错误信息作为拒绝原因
+}
+
+// Install 安装防护插件，按顺序检查规则，命中第一条规则即拒绝
+// 为已注册的集群注册钩子，并在原有回调注册函数的基础上追加，之后注册的集群同样生效
+// 重复调用时替换原有规则，需在 callbacks.RegisterInit 之后调用
+func Install(rules ...Rule) {
+	g := &guard{rules: rules}
+
+	previous := kom.Clusters().GetRegisterCallbackFunc()
+	kom.Clusters().SetRegisterCallbackFunc(func(cluster *kom.ClusterInst) func() {
+		if previous != nil {
+			previous(cluster)
+		}
+		g.register(cluster)
+		return nil
+	})
+	for _, cluster := range kom.Clusters().AllClusters() {
+		g.register(cluster)
+	}
+}
+
+// guard 防护钩子
+type guard struct {
+	rules []Rule
+}
+
+// register 为集群的变更操作注册前置钩子
+func (g *guard) register(cluster *kom.ClusterInst) {
+	k := cluster.Kubectl
+	for _, op := range mutatingOperations {
+		p := k.Callback().Processor(op)
+		if p == nil {
+			klog.V(2).Infof("guard: unknown operation %s", op)
+			continue
+		}
+		op := op
+		p.BeforeHook(hookName, func(k *kom.Kubectl) error {
+			return g.check(k, op)
+		})
+	}
+}
+
+// check 按顺序检查规则
+func (g *guard) check(k *kom.Kubectl, op string) error {
+	stmt := k.Statement
+	ns, name := stmt.Target()
+	req := &Request{
+		Kubectl:   k,
+		ClusterID: k.ID,
+		Operation: op,
+		Kind:      stmt.GVK.Kind,
+		Namespace: ns,
+		Name:      name,
+	}
+	for _, rule := range g.rules {
+		if !matchOperation(rule.Operations, op) {
+			continue
+		}
+		if err := rule.Check(req); err != nil {
+			klog.V(2).Infof("guard: %s %s %s/%s in cluster %s denied by %s: %v", op, req.Kind, ns, name, k.ID, rule.Name, err)
+			return &ErrPolicyDenied{
+				Rule:      rule.Name,
+				Reason:    err.Error(),
+				ClusterID: k.ID,
+				Operation: op,
+				Kind:      req.Kind,
+				Namespace: ns,
+				Name:      name,
+			}
+		}
+	}
+	return nil
+}
+
+func matchOperation(operations []string, op string) bool {
+	if len(operations) == 0 {
+		return true
+	}
+	for _, o := range operations {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold 忽略大小写判断是否包含
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package guard
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// DenyDelete 禁止删除指定类型的资源，如 Namespace、PersistentVolume，忽略大小写
+func DenyDelete(kinds ...string) Rule {
+	return Rule{
+		Name:       fmt.Sprintf("deny-delete:%s", strings.Join(kinds, ",")),
+		Operations: []string{"delete"},
+		Check: func(req *Request) error {
+			if containsFold(kinds, req.Kind) {
+				return fmt.Errorf("delete %s is not allowed", req.Kind)
+			}
+			return nil
+		},
+	}
+}
+
+// DenyMutationInNamespaces 禁止在指定命名空间内执行任何变更操作，包括在容器内执行命令
+func DenyMutationInNamespaces(namespaces ...string) Rule {
+	return Rule{
+		Name: fmt.Sprintf("deny-mutation-in-namespace:%s", strings.Join(namespaces, ",")),
+		Check: func(req *Request) error {
+			for _, ns := range namespaces {
+				if req.Namespace == ns {
+					return fmt.Errorf("namespace %s is read-only", ns)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// DenyExecIntoPods 禁止在标签匹配的Pod内执行命令，语法与k8s标签选择器一致，如 pci=true
+// 执行前会多一次Get请求获取Pod标签，获取失败时拒绝
+func DenyExecIntoPods(selector string) Rule {
+	name := fmt.Sprintf("deny-exec:%s", selector)
+	sel, parseErr := labels.Parse(selector)
+	return Rule{
+		Name:       name,
+		Operations: []string{"exec", "stream-exec"},
+		Check: func(req *Request) error {
+			if parseErr != nil {
+				return fmt.Errorf("invalid pod selector %s: %v", selector, parseErr)
+			}
+			k := req.Kubectl
+			pod, err := k.Client().CoreV1().Pods(req.Namespace).Get(k.Statement.Context, req.Name, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("get pod %s/%s labels error: %v", req.Namespace, req.Name, err)
+			}
+			if sel.Matches(labels.Set(pod.Labels)) {
+				return fmt.Errorf("exec into pod matching %s is not allowed", selector)
+			}
+			return nil
+		},
+	}
+}
+
+// MaxBulkDelete 限制一次批量删除的对象数量，如 Applier().Delete 中包含的对象数
+// 超出时批量操作中的每个对象都会被拒绝
+func MaxBulkDelete(max int) Rule {
+	return Rule{
+		Name:       fmt.Sprintf("max-bulk-delete:%d", max),
+		Operations: []string{"delete"},
+		Check: func(req *Request) error {
+			if size := req.Kubectl.Statement.BatchSize; size > max {
+				return fmt.Errorf("bulk delete of %d objects exceeds the limit of %d", size, max)
+			}
+			return nil
+		},
+	}
+}
@@ -1,17 +1,19 @@
 package utils
 
 import (
+	"reflect"
 	"time"
 
 	"github.com/dgraph-io/ristretto/v2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/klog/v2"
 )
 
 func GetOrSetCache[T any](cache *ristretto.Cache[string, any], cacheKey string, ttl time.Duration, queryFunc func() (T, error)) (T, error) {
 	var zero T
 
-	// 如果未设置 TTL 参数，说明不需要缓存，则直接执行查询方法
-	if ttl <= 0 {
+	// 如果未设置 TTL 参数，或者集群禁用了缓存，说明不需要缓存，则直接执行查询方法
+	if ttl <= 0 || cache == nil {
 		return queryFunc()
 	}
 	// 检查缓存是否命中
@@ -26,9 +28,34 @@ func GetOrSetCache[T any](cache *ristretto.Cache[string, any], cacheKey string,
 		return zero, err
 	}
 
-	// 设置缓存并返回结果
-	cache.SetWithTTL(cacheKey, result, 100, ttl)
+	// 设置缓存并返回结果，cost 为0时由缓存的Cost函数按对象大小计算
+	cache.SetWithTTL(cacheKey, result, 0, ttl)
 	cache.Wait()
 
 	return result, nil
 }
+
+// estimatedObjectCost 估算缓存占用时单个资源对象的字节数
+const estimatedObjectCost = 4 << 10
+
+// EstimateCost 估算缓存对象占用的字节数，写入缓存时调用，不做序列化
+// 字符串及字节切片按长度计算，资源列表及切片按条目数乘以单个对象的估算值计算，其他对象按单个对象计算
+func EstimateCost(value any) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 1
+	case []byte:
+		return max(int64(len(v)), 1)
+	case string:
+		return max(int64(len(v)), 1)
+	case *unstructured.UnstructuredList:
+		if v == nil {
+			return 1
+		}
+		return max(int64(len(v.Items))*estimatedObjectCost, 1)
+	}
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Slice {
+		return max(int64(rv.Len())*estimatedObjectCost, 1)
+	}
+	return estimatedObjectCost
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestEstimateCost(t *testing.T) {
+	list := &unstructured.UnstructuredList{Items: make([]unstructured.Unstructured, 3)}
+	cases := []struct {
+		name  string
+		value any
+		want  int64
+	}{
+		{"nil", nil, 1},
+		{"empty string", "", 1},
+		{"string", "abc", 3},
+		{"bytes", []byte("abcd"), 4},
+		{"list", list, 3 * estimatedObjectCost},
+		{"empty list", &unstructured.UnstructuredList{}, 1},
+		{"slice", make([]*unstructured.Unstructured, 2), 2 * estimatedObjectCost},
+		{"object", &unstructured.Unstructured{}, estimatedObjectCost},
+	}
+	for _, c := range cases {
+		if got := EstimateCost(c.value); got != c.want {
+			t.Errorf("%s cost want %d, got %d", c.name, c.want, got)
+		}
+	}
+}
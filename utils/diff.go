@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffLine 编辑脚本中的一行，kind 为 ' '（相同）、'-'（删除）、'+'（新增）
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// UnifiedDiff 按行对比两段文本，返回 unified diff 格式的差异，没有差异时返回空字符串
+// fromFile、toFile 为差异头部显示的名称，context 为每处变更前后保留的相同行数
+func UnifiedDiff(from, to string, fromFile, toFile string, context int) string {
+	if from == to {
+		return ""
+	}
+	if context < 0 {
+		context = 0
+	}
+	lines := diffLines(splitLines(from), splitLines(to))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromFile, toFile)
+	for start := 0; start < len(lines); {
+		// 找到下一处变更
+		first := start
+		for first < len(lines) && lines[first].kind == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		// 变更之间相同的行不超过2倍context时合并为一个hunk
+		last := first
+		for i := first + 1; i < len(lines); i++ {
+			if lines[i].kind == ' ' {
+				continue
+			}
+			if i-last-1 > 2*context {
+				break
+			}
+			last = i
+		}
+		begin := max(first-context, start)
+		end := min(last+context+1, len(lines))
+		writeHunk(&sb, lines, begin, end)
+		start = end
+	}
+	return sb.String()
+}
+
+// writeHunk 输出 lines[begin:end] 为一个hunk
+func writeHunk(sb *strings.Builder, lines []diffLine, begin, end int) {
+	// hunk之前两侧各自的行数
+	fromLine, toLine := 0, 0
+	for _, l := range lines[:begin] {
+		if l.kind != '+' {
+			fromLine++
+		}
+		if l.kind != '-' {
+			toLine++
+		}
+	}
+	fromCount, toCount := 0, 0
+	for _, l := range lines[begin:end] {
+		if l.kind != '+' {
+			fromCount++
+		}
+		if l.kind != '-' {
+			toCount++
+		}
+	}
+	// 行号从1开始，某一侧没有行时为之前的行号
+	if fromCount > 0 {
+		fromLine++
+	}
+	if toCount > 0 {
+		toLine++
+	}
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(fromLine, fromCount), hunkRange(toLine, toCount))
+	for _, l := range lines[begin:end] {
+		sb.WriteByte(l.kind)
+		sb.WriteString(l.text)
+		sb.WriteByte('\n')
+	}
+}
+
+func hunkRange(line, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+// diffLines 基于 Myers 差异算法生成编辑脚本，使用线性空间的分治实现，
+// 内存占用与两侧行数之和成正比，适用于较大的文本
+func diffLines(a, b []string) []diffLine {
+	return appendDiff(make([]diffLine, 0, len(a)+len(b)), a, b)
+}
+
+// appendDiff 去掉相同的首尾行后，在中间蛇形处拆分并递归对比
+func appendDiff(lines []diffLine, a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for _, text := range a[:prefix] {
+		lines = append(lines, diffLine{' ', text})
+	}
+	a, b = a[prefix:], b[prefix:]
+
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	if x, y, ok := middleSnake(a, b); ok {
+		lines = appendDiff(lines, a[:x], b[:y])
+		lines = appendDiff(lines, a[x:], b[y:])
+	} else {
+		// 一侧为空或没有公共行
+		for _, text := range a {
+			lines = append(lines, diffLine{'-', text})
+		}
+		for _, text := range b {
+			lines = append(lines, diffLine{'+', text})
+		}
+	}
+
+	for _, text := range common {
+		lines = append(lines, diffLine{' ', text})
+	}
+	return lines
+}
+
+// middleSnake 从两端同时搜索最短编辑路径，返回路径在中间相遇的位置 (x, y)
+// 调用方需保证首尾行不同，返回的位置将两侧拆分为更小的子问题；无法拆分时 ok 为false
+func middleSnake(a, b []string) (x, y int, ok bool) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	// forward[k] 为从起点出发、对角线k上到达的最远x；backward[k] 为从终点反向出发的最远距离
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	for i := range forward {
+		forward[i] = -1
+		backward[i] = -1
+	}
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+	delta := n - m
+	// 两侧行数之差为奇数时在正向搜索中检查相遇，否则在反向搜索中检查
+	odd := delta%2 != 0
+	// 越过边界的对角线不再继续搜索
+	var fStart, fEnd, bStart, bEnd int
+
+	for d := 0; d < maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			i := offset + k
+			var fx int
+			if k == -d || (k != d && forward[i-1] < forward[i+1]) {
+				fx = forward[i+1]
+			} else {
+				fx = forward[i-1] + 1
+			}
+			fy := fx - k
+			for fx < n && fy < m && a[fx] == b[fy] {
+				fx++
+				fy++
+			}
+			forward[i] = fx
+			switch {
+			case fx > n:
+				fEnd += 2
+				continue
+			case fy > m:
+				fStart += 2
+				continue
+			case !odd:
+				continue
+			}
+			j := offset + delta - k
+			if j >= 0 && j < len(backward) && backward[j] != -1 && fx >= n-backward[j] {
+				return splitPoint(fx, fy, n, m)
+			}
+		}
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			i := offset + k
+			var bx int
+			if k == -d || (k != d && backward[i-1] < backward[i+1]) {
+				bx = backward[i+1]
+			} else {
+				bx = backward[i-1] + 1
+			}
+			by := bx - k
+			for bx < n && by < m && a[n-bx-1] == b[m-by-1] {
+				bx++
+				by++
+			}
+			backward[i] = bx
+			switch {
+			case bx > n:
+				bEnd += 2
+				continue
+			case by > m:
+				bStart += 2
+				continue
+			case odd:
+				continue
+			}
+			j := offset + delta - k
+			if j >= 0 && j < len(forward) && forward[j] != -1 && forward[j] >= n-bx {
+				fx := forward[j]
+				return splitPoint(fx, fx-(j-offset), n, m)
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// splitPoint 拆分点位于两端时无法缩小问题，视为无法拆分
+func splitPoint(x, y, n, m int) (int, int, bool) {
+	if (x == 0 && y == 0) || (x == n && y == m) {
+		return 0, 0, false
+	}
+	return x, y, true
+}
+
+// splitLines 按行拆分，忽略末尾的换行
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
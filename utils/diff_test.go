@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiffEqual(t *testing.T) {
+	if d := UnifiedDiff("a\nb\n", "a\nb\n", "from", "to", 3); d != "" {
+		t.Errorf("equal text should have no diff, got %q", d)
+	}
+}
+
+func TestUnifiedDiffHunks(t *testing.T) {
+	from := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n"
+	to := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n12.5\n13\n14\n15\n"
+	want := `--- from
++++ to
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,6 +10,7 @@
+ 10
+ 11
+ 12
++12.5
+ 13
+ 14
+ 15
+`
+	if d := UnifiedDiff(from, to, "from", "to", 3); d != want {
+		t.Errorf("diff want\n%s\ngot\n%s", want, d)
+	}
+
+	// 变更之间相同的行不超过2倍context时合并为一个hunk
+	want = `--- from
++++ to
+@@ -1,15 +1,16 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+ 7
+ 8
+ 9
+ 10
+ 11
+ 12
++12.5
+ 13
+ 14
+ 15
+`
+	if d := UnifiedDiff(from, to, "from", "to", 5); d != want {
+		t.Errorf("diff want\n%s\ngot\n%s", want, d)
+	}
+}
+
+func TestUnifiedDiffEmptySide(t *testing.T) {
+	want := "--- from\n+++ to\n@@ -0,0 +1,2 @@\n+a\n+b\n"
+	if d := UnifiedDiff("", "a\nb\n", "from", "to", 3); d != want {
+		t.Errorf("diff want %q, got %q", want, d)
+	}
+	want = "--- from\n+++ to\n@@ -1 +0,0 @@\n-a\n"
+	if d := UnifiedDiff("a\n", "", "from", "to", 3); d != want {
+		t.Errorf("diff want %q, got %q", want, d)
+	}
+}
+
+func TestDiffLinesShortest(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 500; n++ {
+		a := randomLines(r, r.Intn(12))
+		b := randomLines(r, r.Intn(12))
+		lines := diffLines(a, b)
+
+		var from, to []string
+		common := 0
+		for _, l := range lines {
+			if l.kind != '+' {
+				from = append(from, l.text)
+			}
+			if l.kind != '-' {
+				to = append(to, l.text)
+			}
+			if l.kind == ' ' {
+				common++
+			}
+		}
+		if strings.Join(from, ",") != strings.Join(a, ",") || strings.Join(to, ",") != strings.Join(b, ",") {
+			t.Fatalf("edit script of %v -> %v does not rebuild both sides: %v", a, b, lines)
+		}
+		if want := lcsLength(a, b); common != want {
+			t.Fatalf("edit script of %v -> %v keeps %d lines, want %d", a, b, common, want)
+		}
+	}
+}
+
+func TestDiffLinesLarge(t *testing.T) {
+	a := make([]string, 50000)
+	for i := range a {
+		a[i] = fmt.Sprintf("line %d", i)
+	}
+	b := append([]string{}, a...)
+	b[100] = "changed"
+	b = append(b[:30000], b[30010:]...)
+
+	removed, added := 0, 0
+	for _, l := range diffLines(a, b) {
+		switch l.kind {
+		case '-':
+			removed++
+		case '+':
+			added++
+		}
+	}
+	if removed != 11 || added != 1 {
+		t.Errorf("want 11 removed and 1 added, got %d and %d", removed, added)
+	}
+}
+
+func randomLines(r *rand.Rand, n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = string(rune('a' + r.Intn(3)))
+	}
+	return lines
+}
+
+// lcsLength 最长公共子序列长度，用于校验编辑脚本最短
+func lcsLength(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	return lcs[0][0]
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// JSONPatchOperation JSON Patch（RFC 6902）中的一个操作
+type JSONPatchOperation struct {
+	Op    string      `json:"op"` // add、remove、replace
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"` // add、replace 必须带有value，值为null时也输出
+}
+
+// MarshalJSON remove 操作不输出value，其他操作即使value为nil也输出 "value":null
+func (o JSONPatchOperation) MarshalJSON() ([]byte, error) {
+	if o.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{Op: o.Op, Path: o.Path})
+	}
+	type operation JSONPatchOperation
+	return json.Marshal(operation(o))
+}
+
+// CreateJSONPatch 生成从before到after的JSON Patch操作，按路径排序
+// 对象逐个字段对比，数组不同时整体替换
+func CreateJSONPatch(before, after map[string]interface{}) []JSONPatchOperation {
+	var ops []JSONPatchOperation
+	collectPatch("", before, after, &ops)
+	return ops
+}
+
+func collectPatch(prefix string, before, after map[string]interface{}, ops *[]JSONPatchOperation) {
+	keys := make([]string, 0, len(before)+len(after))
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path := prefix + "/" + escapePointer(key)
+		beforeValue, inBefore := before[key]
+		afterValue, inAfter := after[key]
+		switch {
+		case !inAfter:
+			*ops = append(*ops, JSONPatchOperation{Op: "remove", Path: path})
+		case !inBefore:
+			*ops = append(*ops, JSONPatchOperation{Op: "add", Path: path, Value: afterValue})
+		default:
+			beforeMap, beforeIsMap := beforeValue.(map[string]interface{})
+			afterMap, afterIsMap := afterValue.(map[string]interface{})
+			if beforeIsMap && afterIsMap {
+				collectPatch(path, beforeMap, afterMap, ops)
+				continue
+			}
+			beforeJSON, _ := json.Marshal(beforeValue)
+			afterJSON, _ := json.Marshal(afterValue)
+			if string(beforeJSON) != string(afterJSON) {
+				*ops = append(*ops, JSONPatchOperation{Op: "replace", Path: path, Value: afterValue})
+			}
+		}
+	}
+}
+
+// escapePointer 转义JSON Pointer（RFC 6901）中的特殊字符
+func escapePointer(key string) string {
+	key = strings.ReplaceAll(key, "~", "~0")
+	return strings.ReplaceAll(key, "/", "~1")
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCreateJSONPatch(t *testing.T) {
+	before := map[string]interface{}{
+		"a": "1",
+		"m": map[string]interface{}{"x/y": "1", "z": "2", "same": "s"},
+		"l": []interface{}{"1", "2"},
+	}
+	after := map[string]interface{}{
+		"b": "2",
+		"m": map[string]interface{}{"x/y": "3", "same": "s"},
+		"l": []interface{}{"1", "3"},
+	}
+	want := []JSONPatchOperation{
+		{Op: "remove", Path: "/a"},
+		{Op: "add", Path: "/b", Value: "2"},
+		{Op: "replace", Path: "/l", Value: []interface{}{"1", "3"}},
+		{Op: "replace", Path: "/m/x~1y", Value: "3"},
+		{Op: "remove", Path: "/m/z"},
+	}
+	if ops := CreateJSONPatch(before, after); !reflect.DeepEqual(ops, want) {
+		t.Errorf("patch want %v, got %v", want, ops)
+	}
+}
+
+func TestCreateJSONPatchEqual(t *testing.T) {
+	obj := map[string]interface{}{"a": map[string]interface{}{"b": int64(1)}}
+	if ops := CreateJSONPatch(obj, obj); len(ops) != 0 {
+		t.Errorf("equal objects should have no patch, got %v", ops)
+	}
+}
+
+func TestJSONPatchNullValue(t *testing.T) {
+	// add、replace 的value为null时仍需输出，remove 不输出value
+	before := map[string]interface{}{"spec": map[string]interface{}{"old": "1", "m": "x"}}
+	after := map[string]interface{}{"spec": map[string]interface{}{"n": nil, "m": nil}}
+	data, err := json.Marshal(CreateJSONPatch(before, after))
+	if err != nil {
+		t.Fatalf("marshal patch error %v", err)
+	}
+	want := `[{"op":"replace","path":"/spec/m","value":null},{"op":"add","path":"/spec/n","value":null},{"op":"remove","path":"/spec/old"}]`
+	if string(data) != want {
+		t.Errorf("patch json want %s, got %s", want, string(data))
+	}
+}